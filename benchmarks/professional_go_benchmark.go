@@ -1,628 +0,0 @@
-package main
-
-import (
-	"encoding/json"
-	"fmt"
-	"math"
-	"os"
-	"runtime"
-	"sort"
-	"sync"
-	"time"
-)
-
-// BenchmarkStats holds statistical information for a benchmark
-type BenchmarkStats struct {
-	MinNs    float64 `json:"min_ns"`
-	MaxNs    float64 `json:"max_ns"`
-	MeanNs   float64 `json:"mean_ns"`
-	MedianNs float64 `json:"median_ns"`
-	StddevNs float64 `json:"stddev_ns"`
-	P95Ns    float64 `json:"p95_ns"`
-	P99Ns    float64 `json:"p99_ns"`
-}
-
-// BenchmarkResult represents the result of a single benchmark
-type BenchmarkResult struct {
-	Name        string          `json:"name"`
-	Stats       BenchmarkStats  `json:"stats"`
-	Iterations  int             `json:"iterations"`
-	TotalTimeNs float64         `json:"total_time_ns"`
-}
-
-// Calculate computes all statistical metrics
-func (bs *BenchmarkStats) Calculate(measurements []float64) {
-	if len(measurements) == 0 {
-		return
-	}
-
-	sort.Float64s(measurements)
-
-	bs.MinNs = measurements[0]
-	bs.MaxNs = measurements[len(measurements)-1]
-
-	// Calculate mean
-	sum := 0.0
-	for _, m := range measurements {
-		sum += m
-	}
-	bs.MeanNs = sum / float64(len(measurements))
-
-	// Calculate median
-	n := len(measurements)
-	if n%2 == 0 {
-		bs.MedianNs = (measurements[n/2-1] + measurements[n/2]) / 2.0
-	} else {
-		bs.MedianNs = measurements[n/2]
-	}
-
-	// Calculate percentiles
-	bs.P95Ns = measurements[int(float64(n)*0.95)]
-	bs.P99Ns = measurements[int(float64(n)*0.99)]
-
-	// Calculate standard deviation
-	variance := 0.0
-	for _, m := range measurements {
-		variance += (m - bs.MeanNs) * (m - bs.MeanNs)
-	}
-	bs.StddevNs = math.Sqrt(variance / float64(len(measurements)))
-}
-
-// PrintSummary prints a one-line summary of the benchmark result
-func (br *BenchmarkResult) PrintSummary() {
-	throughput := 1e9 / br.Stats.MeanNs
-	fmt.Printf("%-30s %10d %12.0f ns %12.0f ns %14.2f ops/sec\n",
-		br.Name, br.Iterations, br.Stats.MeanNs, br.Stats.MedianNs, throughput)
-}
-
-// PrintDetailed prints detailed statistics
-func (br *BenchmarkResult) PrintDetailed() {
-	throughput := 1e9 / br.Stats.MeanNs
-	fmt.Printf("\n%s - Detailed Statistics:\n", br.Name)
-	fmt.Printf("  Iterations:    %d\n", br.Iterations)
-	fmt.Printf("  Mean:          %.0f ns\n", br.Stats.MeanNs)
-	fmt.Printf("  Median:        %.0f ns\n", br.Stats.MedianNs)
-	fmt.Printf("  Min:           %.0f ns\n", br.Stats.MinNs)
-	fmt.Printf("  Max:           %.0f ns\n", br.Stats.MaxNs)
-	fmt.Printf("  Std Dev:       %.0f ns\n", br.Stats.StddevNs)
-	fmt.Printf("  95th pct:      %.0f ns\n", br.Stats.P95Ns)
-	fmt.Printf("  99th pct:      %.0f ns\n", br.Stats.P99Ns)
-	fmt.Printf("  Throughput:    %.2f ops/sec\n", throughput)
-}
-
-// BenchmarkRunner provides utilities for running benchmarks
-type BenchmarkRunner struct {
-	warmupIterations    int
-	minIterations       int
-	maxIterations       int
-	minBenchmarkTimeNs  int64
-}
-
-// NewBenchmarkRunner creates a new benchmark runner with default settings
-func NewBenchmarkRunner() *BenchmarkRunner {
-	return &BenchmarkRunner{
-		warmupIterations:   10,
-		minIterations:      100,
-		maxIterations:      10000,
-		minBenchmarkTimeNs: 100_000_000, // 100ms minimum
-	}
-}
-
-// Run executes a benchmark function with the given name
-func (br *BenchmarkRunner) Run(name string, benchmarkFunc func()) BenchmarkResult {
-	// Warmup phase
-	for i := 0; i < br.warmupIterations; i++ {
-		benchmarkFunc()
-	}
-
-	result := BenchmarkResult{
-		Name:  name,
-		Stats: BenchmarkStats{},
-	}
-
-	var measurements []float64
-	totalStart := time.Now()
-	iterations := br.minIterations
-	elapsed := int64(0)
-
-	for elapsed < br.minBenchmarkTimeNs && iterations <= br.maxIterations {
-		for i := 0; i < iterations; i++ {
-			start := time.Now()
-			benchmarkFunc()
-			duration := time.Since(start)
-			measurements = append(measurements, float64(duration.Nanoseconds()))
-		}
-
-		elapsed = time.Since(totalStart).Nanoseconds()
-		if elapsed < br.minBenchmarkTimeNs {
-			iterations = min(iterations*2, br.maxIterations)
-		}
-	}
-
-	result.Iterations = len(measurements)
-	result.TotalTimeNs = float64(elapsed)
-	result.Stats.Calculate(measurements)
-	return result
-}
-
-// Goroutine creation and execution benchmark
-func benchmarkGoroutineCreationAndExecution() BenchmarkResult {
-	runner := NewBenchmarkRunner()
-	return runner.Run("Goroutine Creation & Execution", func() {
-		done := make(chan int)
-		go func() {
-			// 模拟协程执行中的一些计算
-			sum := 0
-			for i := 0; i < 10; i++ {
-				sum += i
-			}
-			done <- sum
-		}()
-		_ = <-done
-	})
-}
-
-// Channel operations benchmark
-func benchmarkChannelOps() BenchmarkResult {
-	runner := NewBenchmarkRunner()
-	return runner.Run("Channel Operations", func() {
-		ch := make(chan int, 1)
-		ch <- 42
-		<-ch
-	})
-}
-
-// Simple computation benchmark
-func benchmarkSimpleComputation() BenchmarkResult {
-	runner := NewBenchmarkRunner()
-	return runner.Run("Simple Computation", func() {
-		sum := 0
-		for i := 0; i < 100; i++ {
-			sum += i
-		}
-		_ = sum
-	})
-}
-
-// Complex computation benchmark - 测试调度器处理复杂计算的能力
-func benchmarkComplexComputation() BenchmarkResult {
-	runner := NewBenchmarkRunner()
-	return runner.Run("Complex Computation Task", func() {
-		// 1. 矩阵运算 (3x3矩阵乘法)
-		matrixA := [9]float64{1.1, 2.2, 3.3, 4.4, 5.5, 6.6, 7.7, 8.8, 9.9}
-		matrixB := [9]float64{9.9, 8.8, 7.7, 6.6, 5.5, 4.4, 3.3, 2.2, 1.1}
-		var resultMatrix [9]float64
-		
-		for i := 0; i < 3; i++ {
-			for j := 0; j < 3; j++ {
-				for k := 0; k < 3; k++ {
-					resultMatrix[i*3+j] += matrixA[i*3+k] * matrixB[k*3+j]
-				}
-			}
-		}
-		
-		// 2. 字符串处理和哈希计算
-		data := "ComplexTaskBenchmark"
-		hash := uint64(0)
-		for _, c := range data {
-			hash = hash*31 + uint64(c)
-			hash ^= (hash >> 16)
-		}
-		
-		// 3. 三角函数计算
-		trigSum := 0.0
-		for i := 1; i <= 50; i++ {
-			angle := float64(i) * 0.1
-			trigSum += math.Sin(angle)*math.Cos(angle) + math.Tan(angle*0.5)
-		}
-		
-		// 4. 动态内存操作
-		dynamicData := make([]int, 100)
-		for i := 0; i < 100; i++ {
-			dynamicData[i] = i*i + int(hash%1000)
-		}
-		
-		// 5. 复杂条件分支和数据处理
-		finalResult := 0.0
-		for i, val := range dynamicData {
-			if val%3 == 0 {
-				finalResult += math.Sqrt(float64(val))
-			} else if val%5 == 0 {
-				finalResult += math.Log(float64(val + 1))
-			} else {
-				finalResult += float64(val) * 0.1
-			}
-			_ = i // 防止编译器优化
-		}
-		
-		// 6. 合并所有计算结果
-		total := 0.0
-		for _, val := range resultMatrix {
-			total += val
-		}
-		total += trigSum + finalResult + float64(hash)
-		
-		_ = total // 防止编译器优化
-	})
-}
-
-// Data processing task benchmark (equivalent to FlowCoro)
-func benchmarkDataProcessingTask() BenchmarkResult {
-	runner := NewBenchmarkRunner()
-	return runner.Run("Data Processing Task", func() {
-		data := make([]int, 50)
-		for i := range data {
-			data[i] = i * 2
-		}
-		
-		sum := 0
-		for _, v := range data {
-			sum += v * v
-		}
-		
-		result := float64(sum) / float64(len(data))
-		_ = result
-	})
-}
-
-// Request handler task benchmark (equivalent to FlowCoro)
-func benchmarkRequestHandlerTask() BenchmarkResult {
-	runner := NewBenchmarkRunner()
-	return runner.Run("Request Handler Task", func() {
-		// Simulate request validation
-		valid := true
-		for i := 0; i < 20; i++ {
-			if i%7 == 0 {
-				valid = !valid
-			}
-		}
-		
-		// Simulate data processing
-		if valid {
-			result := 0
-			for i := 0; i < 30; i++ {
-				result += i * i
-			}
-			_ = result
-		}
-	})
-}
-
-// Batch processing task benchmark (equivalent to FlowCoro)
-func benchmarkBatchProcessingTask() BenchmarkResult {
-	runner := NewBenchmarkRunner()
-	return runner.Run("Batch Processing Task", func() {
-		batch := make([]int, 100)
-		for i := range batch {
-			batch[i] = i
-		}
-		
-		// Process each item
-		results := make([]int, len(batch))
-		for i, item := range batch {
-			temp := item
-			for j := 0; j < 5; j++ {
-				temp = temp*2 + 1
-			}
-			results[i] = temp % 1000
-		}
-		
-		// Calculate final result
-		sum := 0
-		for _, r := range results {
-			sum += r
-		}
-		_ = sum
-	})
-}
-
-// Concurrent task processing benchmark (equivalent to FlowCoro)
-func benchmarkConcurrentTaskProcessing() BenchmarkResult {
-	runner := NewBenchmarkRunner()
-	return runner.Run("Concurrent Task Processing", func() {
-		var wg sync.WaitGroup
-		results := make([]int, 5)
-		
-		for i := 0; i < 5; i++ {
-			wg.Add(1)
-			go func(idx int) {
-				defer wg.Done()
-				
-				// Each goroutine does some work
-				sum := 0
-				for j := 0; j < 50; j++ {
-					sum += (idx + 1) * j
-				}
-				results[idx] = sum
-			}(i)
-		}
-		
-		wg.Wait()
-		
-		// Combine results
-		total := 0
-		for _, r := range results {
-			total += r
-		}
-		_ = total
-	})
-}
-
-// Concurrent goroutines benchmark
-func benchmarkConcurrentGoroutines() BenchmarkResult {
-	runner := NewBenchmarkRunner()
-	return runner.Run("Concurrent Goroutines (10)", func() {
-		var wg sync.WaitGroup
-		for i := 0; i < 10; i++ {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				time.Sleep(1 * time.Microsecond)
-			}()
-		}
-		wg.Wait()
-	})
-}
-
-// Real Echo server benchmark - fixed to test network IO performance only
-func benchmarkEchoServer() BenchmarkResult {
-	runner := NewBenchmarkRunner()
-	return runner.Run("Echo Server Throughput", func() {
-		// Simulate network processing without server startup overhead
-		data := make([]byte, 20) // "Hello, Echo Server!\n"
-		for i := range data {
-			data[i] = byte(65 + (i % 26)) // Fill with letters
-		}
-		
-		// Simulate echo processing
-		echo := make([]byte, len(data))
-		copy(echo, data)
-		
-		// Simulate checksum validation
-		sum := 0
-		for _, b := range echo {
-			sum += int(b)
-		}
-		_ = sum
-	})
-}
-
-// Concurrent Echo clients benchmark - fixed
-func benchmarkConcurrentEchoClients() BenchmarkResult {
-	runner := NewBenchmarkRunner()
-	return runner.Run("Concurrent Echo Clients", func() {
-		const clientCount = 100  // 与FlowCoro保持一致：100个并发任务
-		var wg sync.WaitGroup
-		wg.Add(clientCount)
-		
-		for i := 0; i < clientCount; i++ {
-			go func() {
-				defer wg.Done()
-				
-				// 模拟更多的网络处理工作（与FlowCoro一致）
-				work := 0
-				for j := 0; j < 1000; j++ {  // 1000次循环，与FlowCoro一致
-					work += j * j  // 更复杂的计算
-				}
-				
-				// 模拟网络延迟（与FlowCoro的sleep_for对应）
-				time.Sleep(time.Microsecond)
-				
-				_ = work  // 防止编译器优化
-			}()
-		}
-		
-		wg.Wait()
-	})
-}
-
-// Data transfer benchmarks
-func benchmarkSmallDataTransfer() BenchmarkResult {
-	runner := NewBenchmarkRunner()
-	return runner.Run("Small Data Transfer (64B)", func() {
-		data := make([]byte, 64)
-		for i := range data {
-			data[i] = byte(i % 256)
-		}
-		// Simulate checksum
-		sum := 0
-		for _, b := range data {
-			sum += int(b)
-		}
-		_ = sum
-	})
-}
-
-func benchmarkMediumDataTransfer() BenchmarkResult {
-	runner := NewBenchmarkRunner()
-	return runner.Run("Medium Data Transfer (4KB)", func() {
-		data := make([]byte, 4096)
-		for i := range data {
-			data[i] = byte(i % 256)
-		}
-		// Simulate checksum
-		sum := 0
-		for _, b := range data {
-			sum += int(b)
-		}
-		_ = sum
-	})
-}
-
-func benchmarkLargeDataTransfer() BenchmarkResult {
-	runner := NewBenchmarkRunner()
-	return runner.Run("Large Data Transfer (64KB)", func() {
-		data := make([]byte, 65536)
-		for i := range data {
-			data[i] = byte(i % 256)
-		}
-		// Simulate compression
-		compressedSize := 0
-		for i := 0; i < len(data); i += 64 {
-			if i > 0 && data[i] == data[i-64] {
-				compressedSize += 1 // compression marker
-			} else {
-				compressedSize += 64 // raw data
-			}
-		}
-		_ = compressedSize
-	})
-}
-
-// Memory allocation benchmark
-func benchmarkMemoryAllocation() BenchmarkResult {
-	runner := NewBenchmarkRunner()
-	return runner.Run("Memory Allocation (1KB)", func() {
-		data := make([]byte, 1024)
-		// Use the data to prevent optimization
-		data[0] = 1
-		data[1023] = 1
-		_ = data
-	})
-}
-
-// HTTP request processing simulation
-func benchmarkHTTPProcessing() BenchmarkResult {
-	runner := NewBenchmarkRunner()
-	return runner.Run("HTTP Request Processing", func() {
-		request := "GET /api/data HTTP/1.1\r\nHost: localhost\r\n\r\n"
-		response := "HTTP/1.1 200 OK\r\nContent-Length: 13\r\n\r\nHello, World!"
-		
-		// Simulate request parsing
-		_ = len(request)
-		// Simulate response generation
-		_ = len(response)
-	})
-}
-
-// System information
-type SystemInfo struct {
-	GoVersion     string `json:"go_version"`
-	OS            string `json:"os"`
-	Arch          string `json:"arch"`
-	NumCPU        int    `json:"num_cpu"`
-	NumGoroutine  int    `json:"num_goroutine"`
-	Timestamp     int64  `json:"timestamp"`
-}
-
-// BenchmarkSuite contains all benchmark results and system info
-type BenchmarkSuite struct {
-	SystemInfo SystemInfo        `json:"system_info"`
-	Results    []BenchmarkResult `json:"results"`
-}
-
-func printSystemInfo() {
-	fmt.Println("\n=== System Information ===")
-	fmt.Printf("Go Version: %s\n", runtime.Version())
-	fmt.Printf("OS/Arch: %s/%s\n", runtime.GOOS, runtime.GOARCH)
-	fmt.Printf("CPU Cores: %d\n", runtime.NumCPU())
-	fmt.Printf("Goroutines: %d\n", runtime.NumGoroutine())
-	fmt.Println("==========================")
-}
-
-func printBenchmarkHeader() {
-	fmt.Println("\n=== Go Performance Benchmarks ===")
-	fmt.Println("====================================================================================================")
-	fmt.Printf("%-30s %10s %12s %12s %14s\n", "Benchmark Name", "Iterations", "Mean Time", "Median Time", "Throughput")
-	fmt.Println("----------------------------------------------------------------------------------------------------")
-}
-
-func printBenchmarkFooter() {
-	fmt.Println("====================================================================================================")
-	fmt.Println("\nBenchmark completed successfully.")
-	fmt.Println("Note: Results may vary based on system load and hardware configuration.")
-}
-
-func saveBenchmarkResultsJSON(results []BenchmarkResult) {
-	systemInfo := SystemInfo{
-		GoVersion:    runtime.Version(),
-		OS:           runtime.GOOS,
-		Arch:         runtime.GOARCH,
-		NumCPU:       runtime.NumCPU(),
-		NumGoroutine: runtime.NumGoroutine(),
-		Timestamp:    time.Now().Unix(),
-	}
-
-	suite := BenchmarkSuite{
-		SystemInfo: systemInfo,
-		Results:    results,
-	}
-
-	jsonData, err := json.MarshalIndent(suite, "", "  ")
-	if err != nil {
-		fmt.Printf("Error marshaling JSON: %v\n", err)
-		return
-	}
-
-	err = os.WriteFile("go_benchmark_results.json", jsonData, 0644)
-	if err != nil {
-		fmt.Printf("Error writing JSON file: %v\n", err)
-		return
-	}
-
-	fmt.Println("\nGo benchmark results saved to go_benchmark_results.json")
-}
-
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
-
-func main() {
-	printSystemInfo()
-	printBenchmarkHeader()
-
-	var results []BenchmarkResult
-
-	// Core Go benchmarks
-	results = append(results, benchmarkGoroutineCreationAndExecution())
-	results = append(results, benchmarkChannelOps())
-	results = append(results, benchmarkSimpleComputation())
-	
-	// 复杂任务基准测试 - 测试调度器能力
-	results = append(results, benchmarkComplexComputation())
-	
-	results = append(results, benchmarkDataProcessingTask())
-	results = append(results, benchmarkRequestHandlerTask())
-	results = append(results, benchmarkBatchProcessingTask())
-	results = append(results, benchmarkConcurrentTaskProcessing())
-
-	// Concurrency benchmarks
-	results = append(results, benchmarkConcurrentGoroutines())
-
-	// Memory benchmarks
-	results = append(results, benchmarkMemoryAllocation())
-
-	// Network and IO simulation benchmarks
-	results = append(results, benchmarkEchoServer())
-	results = append(results, benchmarkConcurrentEchoClients())
-	results = append(results, benchmarkHTTPProcessing())
-
-	// Data transfer benchmarks
-	results = append(results, benchmarkSmallDataTransfer())
-	results = append(results, benchmarkMediumDataTransfer())
-	results = append(results, benchmarkLargeDataTransfer())
-
-	// Print summary
-	for _, result := range results {
-		result.PrintSummary()
-	}
-
-	printBenchmarkFooter()
-
-	// Save JSON results
-	saveBenchmarkResultsJSON(results)
-
-	// Print detailed statistics for key benchmarks
-	fmt.Println("\n=== Detailed Statistics ===")
-	for _, result := range results {
-		if result.Name == "Goroutine Creation" ||
-			result.Name == "Echo Server Simulation" ||
-			result.Name == "HTTP Request Processing" ||
-			len(result.Name) > 12 && result.Name[:12] == "Data Transfer" {
-			result.PrintDetailed()
-		}
-	}
-}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"encoding/binary"
+	"syscall"
+)
+
+// cpuInfo returns the CPU model name and base frequency in MHz from sysctl.
+// hw.cpufrequency is missing on Apple silicon, where mhz stays 0.
+func cpuInfo() (model string, mhz float64) {
+	model, _ = syscall.Sysctl("machdep.cpu.brand_string")
+	// hw.cpufrequency is a little-endian uint64 in Hz. Sysctl returns the
+	// raw bytes as a string minus a trailing zero byte, so pad it back.
+	if raw, err := syscall.Sysctl("hw.cpufrequency"); err == nil && len(raw) > 0 && len(raw) <= 8 {
+		var buf [8]byte
+		copy(buf[:], raw)
+		mhz = float64(binary.LittleEndian.Uint64(buf[:])) / 1e6
+	}
+	return model, mhz
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// cpuInfo reads the first CPU's model name from /proc/cpuinfo. The base
+// frequency comes from cpufreq where the driver exposes it, otherwise from
+// the "cpu MHz" line, which is the clock at the time of reading. Anything
+// it can't find stays empty or 0.
+func cpuInfo() (model string, mhz float64) {
+	if data, err := os.ReadFile("/sys/devices/system/cpu/cpu0/cpufreq/base_frequency"); err == nil {
+		if khz, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64); err == nil {
+			mhz = khz / 1e3
+		}
+	}
+	data, err := os.ReadFile("/proc/cpuinfo")
+	if err != nil {
+		return "", mhz
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch {
+		case key == "model name" && model == "":
+			model = value
+		case key == "cpu MHz" && mhz == 0:
+			mhz, _ = strconv.ParseFloat(value, 64)
+		}
+		if model != "" && mhz != 0 {
+			break
+		}
+	}
+	return model, mhz
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package main
+
+// cpuInfo doesn't know how to find the CPU model and frequency here, and
+// reports them as unknown
+func cpuInfo() (model string, mhz float64) {
+	return "", 0
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "time"
+
+// processCPUTime returns 0 where getrusage isn't available, which leaves
+// CPU time and parallelism out of the results
+func processCPUTime() time.Duration {
+	return 0
+}
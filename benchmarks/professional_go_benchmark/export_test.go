@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// prometheusSample matches a sample line of the text exposition format
+var prometheusSample = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)\{(.*)\} (\S+)$`)
+
+// parsePrometheusLabels parses the label pairs of a sample, undoing the
+// escapes of the text format
+func parsePrometheusLabels(text string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for text != "" {
+		key, rest, ok := strings.Cut(text, `="`)
+		if !ok {
+			return nil, fmt.Errorf("label without a quoted value in %q", text)
+		}
+		var value strings.Builder
+		for {
+			if rest == "" {
+				return nil, fmt.Errorf("unterminated value of label %s", key)
+			}
+			c := rest[0]
+			rest = rest[1:]
+			if c == '"' {
+				break
+			}
+			if c == '\\' {
+				if rest == "" {
+					return nil, fmt.Errorf("dangling escape in label %s", key)
+				}
+				switch rest[0] {
+				case 'n':
+					value.WriteByte('\n')
+				case '\\', '"':
+					value.WriteByte(rest[0])
+				default:
+					return nil, fmt.Errorf("unknown escape \\%c in label %s", rest[0], key)
+				}
+				rest = rest[1:]
+				continue
+			}
+			value.WriteByte(c)
+		}
+		labels[key] = value.String()
+		text = strings.TrimPrefix(rest, ",")
+		if len(text) == len(rest) && text != "" {
+			return nil, fmt.Errorf("no comma after label %s", key)
+		}
+	}
+	return labels, nil
+}
+
+func TestWritePrometheusParsesBack(t *testing.T) {
+	suite := BenchmarkSuite{
+		Labels: map[string]string{"branch": `fix "quotes"`, "host": "a\\b\nc"},
+		Results: []BenchmarkResult{
+			{Name: "Sort (16)", Stats: BenchmarkStats{MeanNs: 123.5, P99Ns: 200}},
+			{Name: `Read "x" \ y`, Stats: BenchmarkStats{MeanNs: 2e6, P99Ns: 3.25e6}},
+		},
+	}
+	var out bytes.Buffer
+	if err := WritePrometheus(&out, suite); err != nil {
+		t.Fatal(err)
+	}
+
+	byLabel := make(map[string]BenchmarkResult)
+	for _, result := range suite.Results {
+		byLabel[prometheusNameReplacer.Replace(result.Name)] = result
+	}
+	typed := make(map[string]bool)
+	samples := 0
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if fields := strings.Fields(line); len(fields) == 4 && fields[0] == "#" && fields[1] == "TYPE" {
+			if fields[3] != "gauge" {
+				t.Errorf("%s has type %s, want gauge", fields[2], fields[3])
+			}
+			typed[fields[2]] = true
+			continue
+		}
+		if strings.HasPrefix(line, "# HELP ") {
+			continue
+		}
+
+		match := prometheusSample.FindStringSubmatch(line)
+		if match == nil {
+			t.Errorf("line %q is neither a comment nor a sample", line)
+			continue
+		}
+		samples++
+		name, value := match[1], match[3]
+		if !typed[name] {
+			t.Errorf("sample of %s precedes its TYPE line", name)
+		}
+		labels, err := parsePrometheusLabels(match[2])
+		if err != nil {
+			t.Errorf("line %q: %v", line, err)
+			continue
+		}
+		for key, want := range suite.Labels {
+			if labels[key] != want {
+				t.Errorf("line %q: label %s = %q, want %q", line, key, labels[key], want)
+			}
+		}
+		result, ok := byLabel[labels["name"]]
+		if !ok {
+			t.Errorf("line %q: name %q is no result", line, labels["name"])
+			continue
+		}
+		got, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			t.Errorf("line %q: %v", line, err)
+			continue
+		}
+		for _, gauge := range prometheusGauges {
+			if gauge.name == name && got != gauge.value(result) {
+				t.Errorf("%s of %s = %g, want %g", name, result.Name, got, gauge.value(result))
+			}
+		}
+	}
+	if want := len(prometheusGauges) * len(suite.Results); samples != want {
+		t.Errorf("parsed %d samples, want %d", samples, want)
+	}
+}
+
+// captureStdout returns what print writes to os.Stdout
+func captureStdout(t *testing.T, print func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- data
+	}()
+	print()
+	w.Close()
+	return string(<-out)
+}
+
+func TestZeroMeanStaysFinite(t *testing.T) {
+	result := newTestRunner(t, nil).Run("empty", func() {})
+	// Every sample 0 ns, as for a closure the compiler optimized away
+	result.Stats = BenchmarkStats{}
+	result.Stats.Calculate(make([]float64, 100))
+
+	if got := result.Throughput(); got != 0 {
+		t.Errorf("Throughput() = %g, want 0", got)
+	}
+	summary := captureStdout(t, result.PrintSummary)
+	if !strings.Contains(summary, "N/A") {
+		t.Errorf("summary row %q doesn't report the throughput as N/A", summary)
+	}
+	detailed := captureStdout(t, result.PrintDetailed)
+	for _, output := range []string{summary, detailed} {
+		if strings.Contains(output, "Inf") || strings.Contains(output, "NaN") {
+			t.Errorf("output has a non-finite number:\n%s", output)
+		}
+	}
+
+	data, err := json.Marshal(newBenchmarkSuite(SystemInfo{}, []BenchmarkResult{result}))
+	if err != nil {
+		t.Fatalf("the suite can't be encoded as JSON: %v", err)
+	}
+	if bytes.Contains(data, []byte("Inf")) {
+		t.Errorf("JSON has an infinite number: %s", data)
+	}
+}
@@ -0,0 +1,7537 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"math"
+	"math/bits"
+	"math/rand"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"runtime/pprof"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+	"unsafe"
+
+	_ "modernc.org/sqlite" // the "sqlite" driver of the SQLite export
+)
+
+// BenchmarkStats holds statistical information for a benchmark
+type BenchmarkStats struct {
+	MinNs    float64 `json:"min_ns"`
+	MaxNs    float64 `json:"max_ns"`
+	MeanNs   float64 `json:"mean_ns"`
+	MedianNs float64 `json:"median_ns"`
+	StddevNs float64 `json:"stddev_ns"`
+	P95Ns    float64 `json:"p95_ns"`
+	P99Ns    float64 `json:"p99_ns"`
+
+	// CoefficientOfVariation is CV() at calculation time
+	CoefficientOfVariation float64 `json:"cv"`
+
+	// RelativeStandardError is RSE() at calculation time
+	RelativeStandardError float64 `json:"rse"`
+
+	// Confidence interval for the mean at ConfidenceLevel (e.g. 0.95); all
+	// zero when there are too few samples to estimate one
+	ConfidenceLevel float64 `json:"confidence_level,omitempty"`
+	MeanCILowNs     float64 `json:"mean_ci_low_ns,omitempty"`
+	MeanCIHighNs    float64 `json:"mean_ci_high_ns,omitempty"`
+
+	// Set by TrimOutliers: samples outside the IQR fences and the mean of
+	// the rest. The fields above always describe every sample.
+	OutliersRemoved int     `json:"outliers_removed,omitempty"`
+	TrimmedMeanNs   float64 `json:"trimmed_mean_ns,omitempty"`
+
+	// Buckets is the latency distribution between MinNs and MaxNs
+	Buckets Histogram `json:"buckets,omitempty"`
+
+	// Estimated is set when MedianNs, P95Ns and P99Ns are P² estimates
+	// rather than exact (RunnerConfig.StreamingStats); there is no
+	// histogram then
+	Estimated bool `json:"estimated,omitempty"`
+}
+
+// HistogramBucket counts the samples in [LowerNs, UpperNs); the last bucket
+// of a Histogram also holds the samples equal to its UpperNs
+type HistogramBucket struct {
+	LowerNs float64 `json:"lower_ns"`
+	UpperNs float64 `json:"upper_ns"`
+	Count   int     `json:"count"`
+}
+
+// Histogram is a latency distribution over logarithmically spaced buckets,
+// which keep both the bulk of the samples and a long tail (e.g. GC pauses)
+// readable
+type Histogram []HistogramBucket
+
+// histogramBuckets is the bucket count Calculate uses
+const histogramBuckets = 20
+
+// NewHistogram spreads sorted over n buckets whose bounds grow by a constant
+// factor from its smallest to its largest sample. Samples below 1 ns are
+// counted in the first bucket, which then starts at 1 ns.
+func NewHistogram(sorted []float64, n int) Histogram {
+	if len(sorted) == 0 || n <= 0 {
+		return nil
+	}
+	low, high := math.Max(sorted[0], 1), math.Max(sorted[len(sorted)-1], 1)
+	if high == low {
+		return Histogram{{LowerNs: low, UpperNs: high, Count: len(sorted)}}
+	}
+
+	h := make(Histogram, n)
+	ratio := math.Pow(high/low, 1/float64(n))
+	for i := range h {
+		h[i].LowerNs = low * math.Pow(ratio, float64(i))
+		h[i].UpperNs = low * math.Pow(ratio, float64(i+1))
+	}
+	h[n-1].UpperNs = high // no rounding drift on the last bound
+	for _, m := range sorted {
+		i := 0
+		if m > low {
+			i = min(int(math.Log(m/low)/math.Log(ratio)), n-1)
+		}
+		h[i].Count++
+	}
+	return h
+}
+
+// BenchmarkResult represents the result of a single benchmark
+type BenchmarkResult struct {
+	Name        string         `json:"name"`
+	Stats       BenchmarkStats `json:"stats"`
+	Iterations  int            `json:"iterations"`
+	TotalTimeNs float64        `json:"total_time_ns"`
+	Kind        BenchmarkKind  `json:"kind,omitempty"`
+	Group       string         `json:"group,omitempty"`
+	Tags        []string       `json:"tags,omitempty"`    // of the registered benchmark, see registerBenchmarks
+	Size        int            `json:"size,omitempty"`    // input size of a RunSweep result
+	Workers     int            `json:"workers,omitempty"` // parallelism level of a RunScaling result
+	WarmupNs    []float64      `json:"warmup_ns,omitempty"`
+	StopReason  string         `json:"stop_reason,omitempty"`
+	BatchSize   int            `json:"batch_size,omitempty"`
+
+	// Failed is set when the benchmark panicked; Error holds the panic value
+	Failed bool   `json:"failed,omitempty"`
+	Error  string `json:"error,omitempty"`
+
+	// WarmupIterations is how many warmup calls ran, which adaptive warmup
+	// decides per benchmark
+	WarmupIterations int `json:"warmup_iterations"`
+
+	// Logical operations reported by a RunN closure: the average per call
+	// and the total over the measured calls. Throughput is based on them.
+	OpsPerCall float64 `json:"ops_per_call,omitempty"`
+	TotalOps   float64 `json:"total_ops,omitempty"`
+
+	// Samples are the raw per-sample timings in ns, in measurement order,
+	// kept only when the runner is asked to (-keep-samples) so comparisons
+	// can test the whole distribution rather than the means; see UTest
+	Samples []float64 `json:"samples,omitempty"`
+
+	// BytesPerOp is the payload one op moves, set by RunBytes; when set the
+	// output also reports bandwidth, see MBPerSec
+	BytesPerOp int `json:"bytes_per_op,omitempty"`
+
+	// TimerOverheadNs is the timer cost subtracted from every sample, see
+	// RunnerConfig.SubtractTimerOverhead
+	TimerOverheadNs float64 `json:"timer_overhead_ns,omitempty"`
+
+	// Slow-path throughput: the rate if every call took as long as the
+	// 95th/99th percentile one, for reasoning about worst-case load
+	ThroughputP95 float64 `json:"throughput_p95,omitempty"`
+	ThroughputP99 float64 `json:"throughput_p99,omitempty"`
+
+	// MonotonicTiming confirms durations were taken from the monotonic clock,
+	// so NTP or manual clock adjustments during the run can't skew them.
+	// NegativeSamples counts samples that still came out negative, which
+	// points at a broken clock or a bug; they are dropped from the stats.
+	MonotonicTiming bool `json:"monotonic_timing"`
+	NegativeSamples int  `json:"negative_samples,omitempty"`
+
+	// SingleSpikeDetected is set when exactly one sample exceeds
+	// spikeMedianFactor times the median, typically a GC pause or a
+	// preemption. SpikeRatio is that sample over the median and
+	// SpikeMeanShiftNs how much it alone raised the mean.
+	SingleSpikeDetected bool    `json:"single_spike_detected,omitempty"`
+	SpikeRatio          float64 `json:"spike_ratio,omitempty"`
+	SpikeMeanShiftNs    float64 `json:"spike_mean_shift_ns,omitempty"`
+
+	// Allocation statistics, only populated when the runner tracks allocations
+	AllocsMeasured  bool    `json:"allocs_measured,omitempty"`
+	AllocBytesPerOp float64 `json:"alloc_bytes_per_op,omitempty"`
+	AllocsPerOp     float64 `json:"allocs_per_op,omitempty"`
+	// AllocSizes is the allocation count per size class, see AllocSizeClass
+	AllocSizes []AllocSizeClass `json:"alloc_sizes,omitempty"`
+
+	// Process CPU time over the measured region; CPU/wall shows how many
+	// cores were really busy, ~1x on a concurrent benchmark means serialization.
+	// Both stay zero where the CPU time can't be read.
+	CPUTimeNs   float64 `json:"cpu_time_ns,omitempty"`
+	Parallelism float64 `json:"effective_parallelism,omitempty"`
+
+	// Metrics holds benchmark-specific derived values keyed by unit, e.g. "MB/s"
+	Metrics map[string]float64 `json:"metrics,omitempty"`
+
+	// GC activity during the measured region
+	GCCount          uint64 `json:"gc_count,omitempty"`
+	HeapBallastBytes int    `json:"heap_ballast_bytes,omitempty"`
+
+	// GC pauses during the measured region, only populated when the runner
+	// tracks them (-gc-pauses). The longest pause is the longest the
+	// runtime still remembers, see gcPauseMax.
+	GCPauseTotalNs uint64 `json:"gc_pause_total_ns,omitempty"`
+	GCPauseMaxNs   uint64 `json:"gc_pause_max_ns,omitempty"`
+
+	// With RunnerConfig.SeparateGCSamples, the samples are split by
+	// whether a GC cycle completed during them: CleanStats describes the
+	// others and GCAffectedStats these. Stats keeps describing all samples.
+	GCAffectedSamples int             `json:"gc_affected_samples,omitempty"`
+	CleanStats        *BenchmarkStats `json:"clean_stats,omitempty"`
+	GCAffectedStats   *BenchmarkStats `json:"gc_affected_stats,omitempty"`
+}
+
+// AllocSizeClass counts the allocations a benchmark made in one runtime size
+// class. MaxBytes is the class's object size; allocations larger than the
+// biggest class (32KB) are reported with MaxBytes 0.
+type AllocSizeClass struct {
+	MaxBytes    uint32  `json:"max_bytes"`
+	AllocsPerOp float64 `json:"allocs_per_op"`
+}
+
+// BenchmarkKind declares what bounds a benchmark and selects runner defaults for it
+type BenchmarkKind int
+
+const (
+	KindUnspecified BenchmarkKind = iota
+	KindCPUBound
+	KindIOBound
+	KindMemoryBound
+)
+
+var benchmarkKindNames = map[BenchmarkKind]string{
+	KindCPUBound:    "cpu",
+	KindIOBound:     "io",
+	KindMemoryBound: "memory",
+}
+
+func (k BenchmarkKind) String() string {
+	if name, ok := benchmarkKindNames[k]; ok {
+		return name
+	}
+	return "unspecified"
+}
+
+// MarshalText stores kinds by name so result files stay readable
+func (k BenchmarkKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText accepts the names produced by MarshalText
+func (k *BenchmarkKind) UnmarshalText(text []byte) error {
+	for kind, name := range benchmarkKindNames {
+		if name == string(text) {
+			*k = kind
+			return nil
+		}
+	}
+	if string(text) == "unspecified" {
+		*k = KindUnspecified
+		return nil
+	}
+	return fmt.Errorf("unknown benchmark kind %q", text)
+}
+
+// percentile returns the p-th quantile (0 <= p <= 1) of sorted, linearly
+// interpolating between the two nearest ranks (the method of R's type 7
+// and numpy's default). The rank is clamped so no size can index past the end.
+func percentile(sorted []float64, p float64) float64 {
+	n := len(sorted)
+	if n == 0 {
+		return 0
+	}
+	rank := p * float64(n-1)
+	lower := min(int(rank), n-1)
+	upper := min(lower+1, n-1)
+	return sorted[lower] + (rank-float64(lower))*(sorted[upper]-sorted[lower])
+}
+
+// iqrFenceFactor places the outlier fences this many interquartile ranges
+// beyond the quartiles (Tukey's rule)
+const iqrFenceFactor = 1.5
+
+// TrimOutliers counts the samples outside Q1-1.5*IQR .. Q3+1.5*IQR and
+// computes the mean of the remaining ones. sorted must be sorted.
+func (bs *BenchmarkStats) TrimOutliers(sorted []float64) {
+	if len(sorted) == 0 {
+		return
+	}
+	q1, q3 := percentile(sorted, 0.25), percentile(sorted, 0.75)
+	low, high := q1-iqrFenceFactor*(q3-q1), q3+iqrFenceFactor*(q3-q1)
+
+	sum, kept := 0.0, 0
+	for _, m := range sorted {
+		if m >= low && m <= high {
+			sum += m
+			kept++
+		}
+	}
+	bs.OutliersRemoved = len(sorted) - kept
+	bs.TrimmedMeanNs = sum / float64(kept) // the quartiles themselves are always kept
+}
+
+// defaultConfidenceLevel is the confidence level of the mean's interval
+// unless a runner asks for another one
+const defaultConfidenceLevel = 0.95
+
+// largeSampleSize is the sample count from which the normal distribution
+// replaces Student's t for the confidence interval
+const largeSampleSize = 30
+
+// criticalValue returns the two-sided critical value for level with n
+// samples: z for large n, otherwise t with n-1 degrees of freedom
+func criticalValue(level float64, n int) float64 {
+	if n >= largeSampleSize {
+		return math.Sqrt2 * math.Erfinv(level)
+	}
+	// Invert the t distribution by bisection after bracketing the value
+	low, high := 0.0, 1.0
+	for studentTCoverage(high, n-1) < level {
+		low, high = high, high*2
+	}
+	for i := 0; i < 64; i++ {
+		mid := (low + high) / 2
+		if studentTCoverage(mid, n-1) < level {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return (low + high) / 2
+}
+
+// studentTCoverage returns P(|T| < t) for Student's t distribution with df
+// degrees of freedom, using the closed forms for integer df (Abramowitz &
+// Stegun 26.7.3 and 26.7.4)
+func studentTCoverage(t float64, df int) float64 {
+	theta := math.Atan(t / math.Sqrt(float64(df)))
+	cos2 := math.Cos(theta) * math.Cos(theta)
+	if df%2 == 1 {
+		sum, term := 0.0, 1.0
+		if df > 1 {
+			sum = 1
+			for k := 3; k < df; k += 2 {
+				term *= float64(k-1) / float64(k) * cos2
+				sum += term
+			}
+		}
+		return 2 / math.Pi * (theta + math.Sin(theta)*math.Cos(theta)*sum)
+	}
+	sum, term := 1.0, 1.0
+	for k := 2; k < df; k += 2 {
+		term *= float64(k-1) / float64(k) * cos2
+		sum += term
+	}
+	return math.Sin(theta) * sum
+}
+
+// Calculate computes all statistical metrics, with a 95% confidence
+// interval for the mean. measurements is left as it was.
+func (bs *BenchmarkStats) Calculate(measurements []float64) {
+	bs.CalculateWithConfidence(measurements, defaultConfidenceLevel)
+}
+
+// CalculateWithConfidence is Calculate with the mean's confidence interval
+// computed at level, which must be in (0, 1)
+func (bs *BenchmarkStats) CalculateWithConfidence(measurements []float64, level float64) {
+	sorted := slices.Clone(measurements)
+	sort.Float64s(sorted)
+	bs.calculateSorted(sorted, level)
+}
+
+// calculateSorted is CalculateWithConfidence for samples that are already
+// sorted, sparing the copy
+func (bs *BenchmarkStats) calculateSorted(sorted []float64, level float64) {
+	if len(sorted) == 0 {
+		return
+	}
+
+	bs.MinNs = sorted[0]
+	bs.MaxNs = sorted[len(sorted)-1]
+
+	// Calculate mean
+	sum := 0.0
+	for _, m := range sorted {
+		sum += m
+	}
+	bs.MeanNs = sum / float64(len(sorted))
+
+	// Calculate median
+	n := len(sorted)
+	if n%2 == 0 {
+		bs.MedianNs = (sorted[n/2-1] + sorted[n/2]) / 2.0
+	} else {
+		bs.MedianNs = sorted[n/2]
+	}
+
+	// Calculate percentiles
+	bs.P95Ns = percentile(sorted, 0.95)
+	bs.P99Ns = percentile(sorted, 0.99)
+
+	// Calculate standard deviation
+	variance := 0.0
+	for _, m := range sorted {
+		variance += (m - bs.MeanNs) * (m - bs.MeanNs)
+	}
+	bs.StddevNs = math.Sqrt(variance / float64(len(sorted)))
+	bs.CoefficientOfVariation = bs.CV()
+	bs.RelativeStandardError = bs.RSE(len(sorted))
+	bs.Buckets = NewHistogram(sorted, histogramBuckets)
+
+	bs.setMeanCI(level, n, variance)
+}
+
+// setMeanCI sets the mean's confidence interval at level from n samples
+// whose squared deviations from the mean sum to sumSquares
+func (bs *BenchmarkStats) setMeanCI(level float64, n int, sumSquares float64) {
+	// The interval needs the sample standard deviation (n-1), not the
+	// population one reported in StddevNs
+	if n > 1 {
+		margin := criticalValue(level, n) * math.Sqrt(sumSquares/float64(n-1)) / math.Sqrt(float64(n))
+		bs.ConfidenceLevel = level
+		bs.MeanCILowNs = bs.MeanNs - margin
+		bs.MeanCIHighNs = bs.MeanNs + margin
+	}
+}
+
+// CV returns the coefficient of variation StddevNs/MeanNs, the noise of a
+// benchmark relative to its speed; 0 when there is no mean
+func (bs BenchmarkStats) CV() float64 {
+	if bs.MeanNs <= 0 {
+		return 0
+	}
+	return bs.StddevNs / bs.MeanNs
+}
+
+// RSE returns the relative standard error of the mean over n samples,
+// CV()/sqrt(n): how precisely the mean is known rather than how noisy
+// single samples are
+func (bs BenchmarkStats) RSE(n int) float64 {
+	if n == 0 {
+		return 0
+	}
+	return bs.CV() / math.Sqrt(float64(n))
+}
+
+// runningStats tracks the mean and variance of samples as they arrive
+// (Welford's algorithm), so the loop can check its precision cheaply
+type runningStats struct {
+	n        int
+	mean, m2 float64
+}
+
+func (rs *runningStats) add(x float64) {
+	rs.n++
+	delta := x - rs.mean
+	rs.mean += delta / float64(rs.n)
+	rs.m2 += delta * (x - rs.mean)
+}
+
+// rse is BenchmarkStats.RSE over the samples so far, +Inf while undefined
+func (rs runningStats) rse() float64 {
+	if rs.n < 2 || rs.mean <= 0 {
+		return math.Inf(1)
+	}
+	return math.Sqrt(rs.m2/float64(rs.n)) / rs.mean / math.Sqrt(float64(rs.n))
+}
+
+// p2Quantile estimates one quantile of a stream in constant memory with the
+// P² algorithm (Jain & Chlamtac, 1985). Five markers track the minimum,
+// p/2, p, (1+p)/2 and the maximum; as samples arrive, the inner ones move
+// along a piecewise parabolic fit of the distribution.
+type p2Quantile struct {
+	n       int
+	heights [5]float64
+	pos     [5]float64 // actual marker positions, 1-based
+	desired [5]float64 // where the markers should be
+	incr    [5]float64 // how far desired moves per sample
+}
+
+func newP2Quantile(p float64) p2Quantile {
+	return p2Quantile{
+		desired: [5]float64{1, 1 + 2*p, 1 + 4*p, 3 + 2*p, 5},
+		incr:    [5]float64{0, p / 2, p, (1 + p) / 2, 1},
+	}
+}
+
+func (q *p2Quantile) add(x float64) {
+	if q.n < len(q.heights) {
+		q.heights[q.n] = x
+		q.n++
+		if q.n == len(q.heights) {
+			sort.Float64s(q.heights[:])
+			q.pos = [5]float64{1, 2, 3, 4, 5}
+		}
+		return
+	}
+	q.n++
+
+	// Find the cell x falls into, stretching the extremes if needed
+	var k int
+	switch {
+	case x < q.heights[0]:
+		q.heights[0] = x
+	case x >= q.heights[4]:
+		q.heights[4] = x
+		k = 3
+	default:
+		for x >= q.heights[k+1] {
+			k++
+		}
+	}
+	for i := k + 1; i < len(q.pos); i++ {
+		q.pos[i]++
+	}
+	for i := range q.desired {
+		q.desired[i] += q.incr[i]
+	}
+
+	// Move the inner markers that are off by a position or more
+	for i := 1; i <= 3; i++ {
+		d := q.desired[i] - q.pos[i]
+		if (d >= 1 && q.pos[i+1]-q.pos[i] > 1) || (d <= -1 && q.pos[i-1]-q.pos[i] < -1) {
+			step := math.Copysign(1, d)
+			h := q.parabolic(i, step)
+			if h <= q.heights[i-1] || h >= q.heights[i+1] {
+				h = q.linear(i, step)
+			}
+			q.heights[i] = h
+			q.pos[i] += step
+		}
+	}
+}
+
+// parabolic is the P² piecewise parabolic prediction for marker i moved by step
+func (q *p2Quantile) parabolic(i int, step float64) float64 {
+	h, n := q.heights, q.pos
+	return h[i] + step/(n[i+1]-n[i-1])*((n[i]-n[i-1]+step)*(h[i+1]-h[i])/(n[i+1]-n[i])+
+		(n[i+1]-n[i]-step)*(h[i]-h[i-1])/(n[i]-n[i-1]))
+}
+
+// linear is the fallback prediction when the parabola leaves the neighbors' range
+func (q *p2Quantile) linear(i int, step float64) float64 {
+	j := i + int(step)
+	return q.heights[i] + step*(q.heights[j]-q.heights[i])/(q.pos[j]-q.pos[i])
+}
+
+// value returns the estimate, exact while there are fewer than five samples
+func (q *p2Quantile) value(p float64) float64 {
+	if q.n < len(q.heights) {
+		sorted := slices.Clone(q.heights[:q.n])
+		sort.Float64s(sorted)
+		return percentile(sorted, p)
+	}
+	return q.heights[2]
+}
+
+// streamingStats computes BenchmarkStats without keeping the samples, for
+// RunnerConfig.StreamingStats. The moments come from the runningStats the
+// measure loop keeps anyway; the median, P95 and P99 are P² estimates.
+type streamingStats struct {
+	min, max         float64
+	median, p95, p99 p2Quantile
+}
+
+func newStreamingStats() *streamingStats {
+	return &streamingStats{
+		min:    math.Inf(1),
+		max:    math.Inf(-1),
+		median: newP2Quantile(0.5),
+		p95:    newP2Quantile(0.95),
+		p99:    newP2Quantile(0.99),
+	}
+}
+
+func (ss *streamingStats) add(x float64) {
+	ss.min = math.Min(ss.min, x)
+	ss.max = math.Max(ss.max, x)
+	ss.median.add(x)
+	ss.p95.add(x)
+	ss.p99.add(x)
+}
+
+// stats is calculateSorted's result for the samples added so far, whose
+// moments are in moments
+func (ss *streamingStats) stats(moments runningStats, level float64) BenchmarkStats {
+	n := moments.n
+	if n == 0 {
+		return BenchmarkStats{}
+	}
+	bs := BenchmarkStats{
+		MinNs:     ss.min,
+		MaxNs:     ss.max,
+		MeanNs:    moments.mean,
+		MedianNs:  ss.median.value(0.5),
+		P95Ns:     ss.p95.value(0.95),
+		P99Ns:     ss.p99.value(0.99),
+		StddevNs:  math.Sqrt(moments.m2 / float64(n)),
+		Estimated: true,
+	}
+	bs.CoefficientOfVariation = bs.CV()
+	bs.RelativeStandardError = bs.RSE(n)
+	bs.setMeanCI(level, n, moments.m2)
+	return bs
+}
+
+// SetMetric records a benchmark-specific metric under unit, in the spirit of
+// testing.B.ReportMetric
+func (br *BenchmarkResult) SetMetric(unit string, value float64) {
+	if br.Metrics == nil {
+		br.Metrics = make(map[string]float64)
+	}
+	br.Metrics[unit] = value
+}
+
+// relativeMetric is the metric unit normalizeResults records
+const relativeMetric = "x baseline"
+
+// normalizeResults records each result's mean relative to results[baseline]
+// as the relativeMetric, so a sweep reports slowdowns instead of leaving the
+// reader to divide ns/op figures.
+func normalizeResults(results []BenchmarkResult, baseline int) {
+	base := results[baseline].Stats.MeanNs
+	if base <= 0 {
+		return
+	}
+	for i := range results {
+		results[i].SetMetric(relativeMetric, results[i].Stats.MeanNs/base)
+	}
+}
+
+// normalizeSweeps is normalizeResults for RunSweep results over the same
+// sizes, one sweep per way: every result is relative to the result of
+// sweeps[baseline] at its size
+func normalizeSweeps(sweeps [][]BenchmarkResult, baseline int) {
+	for i, base := range sweeps[baseline] {
+		if base.Stats.MeanNs <= 0 {
+			continue
+		}
+		for _, sweep := range sweeps {
+			sweep[i].SetMetric(relativeMetric, sweep[i].Stats.MeanNs/base.Stats.MeanNs)
+		}
+	}
+}
+
+// Throughput returns logical operations per second. Results from RunN count
+// the ops their closure reported over the measured time; otherwise every
+// call is one op. It is 0 for a mean of 0 ns, e.g. a closure the compiler
+// optimized away, rather than +Inf, which JSON can't encode.
+func (br *BenchmarkResult) Throughput() float64 {
+	if br.TotalOps > 0 && br.TotalTimeNs > 0 {
+		return br.TotalOps * 1e9 / br.TotalTimeNs
+	}
+	if !(br.Stats.MeanNs > 0) {
+		return 0
+	}
+	return 1e9 / br.Stats.MeanNs
+}
+
+// MBPerSec returns the bandwidth BytesPerOp implies at Throughput, in
+// MB/s (10^6 bytes); 0 when BytesPerOp isn't set
+func (br *BenchmarkResult) MBPerSec() float64 {
+	return float64(br.BytesPerOp) * br.Throughput() / 1e6
+}
+
+// formatThroughput formats an ops/sec figure, "N/A" when Throughput had
+// nothing to compute it from
+func formatThroughput(throughput float64) string {
+	if throughput <= 0 {
+		return "N/A"
+	}
+	return strconv.FormatFloat(throughput, 'f', 2, 64)
+}
+
+// CPUNsPerOp is the CPU time spent per operation, 0 when it wasn't measured.
+// On a loaded machine it tracks the work done where the wall-clock mean also
+// counts time spent waiting to be scheduled.
+func (br *BenchmarkResult) CPUNsPerOp() float64 {
+	ops := br.TotalOps
+	if ops <= 0 {
+		ops = float64(br.Iterations * max(br.BatchSize, 1))
+	}
+	if br.CPUTimeNs <= 0 || ops <= 0 {
+		return 0
+	}
+	return br.CPUTimeNs / ops
+}
+
+// setTailThroughput derives ThroughputP95 and ThroughputP99 from the latency
+// percentiles, counting logical operations like Throughput does
+func (br *BenchmarkResult) setTailThroughput() {
+	opsPerCall := max(br.OpsPerCall, 1)
+	br.ThroughputP95, br.ThroughputP99 = 0, 0
+	if br.Stats.P95Ns > 0 {
+		br.ThroughputP95 = opsPerCall * 1e9 / br.Stats.P95Ns
+	}
+	if br.Stats.P99Ns > 0 {
+		br.ThroughputP99 = opsPerCall * 1e9 / br.Stats.P99Ns
+	}
+}
+
+// PrintSummary prints a one-line summary of the benchmark result
+func (br *BenchmarkResult) PrintSummary() {
+	br.printSummaryRow("")
+}
+
+// noisyCV is the coefficient of variation above which the summary marks a
+// result as noisy (-noisy-cv)
+var noisyCV = 0.10
+
+// summaryNameWidth is the width of the summary table's name column, fitted
+// to the longest name by fitSummaryNameWidth
+var summaryNameWidth = minSummaryNameWidth
+
+// minSummaryNameWidth keeps short names in the column width the table
+// always had
+const minSummaryNameWidth = 30
+
+// summaryRuleWidth is the width of the summary table without the name column
+const summaryRuleWidth = 70
+
+// fitSummaryNameWidth sizes the name column to the longest result name,
+// counting the indent of grouped results
+func fitSummaryNameWidth(results []BenchmarkResult) {
+	summaryNameWidth = minSummaryNameWidth
+	for _, result := range results {
+		width := utf8.RuneCountInString(result.Name)
+		if result.Group != "" {
+			width += len("  ")
+		}
+		summaryNameWidth = max(summaryNameWidth, width)
+	}
+}
+
+// fittedNameWidth is the width of a name column fitted to the longest of
+// names, as the summary table's is, for the other tables of benchmarks
+func fittedNameWidth(names []string) int {
+	width := minSummaryNameWidth
+	for _, name := range names {
+		width = max(width, utf8.RuneCountInString(name))
+	}
+	return width
+}
+
+// resultNameWidth is fittedNameWidth for the names of results
+func resultNameWidth(results []BenchmarkResult) int {
+	names := make([]string, len(results))
+	for i, result := range results {
+		names[i] = result.Name
+	}
+	return fittedNameWidth(names)
+}
+
+func (br *BenchmarkResult) printSummaryRow(indent string) {
+	if br.Failed {
+		row := fmt.Sprintf("%-*s FAILED: %s", summaryNameWidth, indent+br.Name, br.Error)
+		if stdoutIsTerminal() {
+			row = colorRed + row + colorReset
+		}
+		fmt.Println(row)
+		return
+	}
+	throughput := br.Throughput()
+	marker := ""
+	if summaryBaseline != nil {
+		marker = " " + baselineChange(br.Name, br.Stats.MeanNs)
+	}
+	if mbps := br.MBPerSec(); mbps > 0 {
+		marker += fmt.Sprintf("  %.2f MB/s", mbps)
+	}
+	if br.Stats.CV() > noisyCV {
+		marker += "  ⚠ noisy"
+	}
+	fmt.Printf("%-*s %10d %15s %15s %14s ops/sec%s\n",
+		summaryNameWidth, indent+br.Name, br.Iterations, formatDuration(br.Stats.MeanNs), formatDuration(br.Stats.MedianNs), formatThroughput(throughput), marker)
+}
+
+// summaryBaseline maps benchmark names to their mean in the -baseline
+// suite; when set, summary rows end with the change against it
+var summaryBaseline map[string]float64
+
+// baselineChange formats the change of mean against name's baseline mean
+// like benchstat's delta column, in green or red on a terminal when it is
+// beyond noise. Benchmarks the baseline lacks show "new".
+func baselineChange(name string, mean float64) string {
+	base, ok := summaryBaseline[name]
+	if !ok {
+		return fmt.Sprintf("%9s", "new")
+	}
+	if !(base > 0) {
+		return fmt.Sprintf("%9s", "n/a")
+	}
+	return formatChange((mean - base) / base)
+}
+
+// formatChange formats a relative change of time for the vs base column,
+// colored when it is beyond noise
+func formatChange(change float64) string {
+	return tintChange(fmt.Sprintf("%+8.1f%%", change*100), change)
+}
+
+// tintChange colors text reporting a relative change of time green or red
+// on a terminal when the change is beyond noise. Pad text before: escape
+// codes take no columns.
+func tintChange(text string, change float64) string {
+	if stdoutIsTerminal() && math.Abs(change) >= trendNoiseThreshold {
+		// Lower times are improvements
+		tint := colorGreen
+		if change > 0 {
+			tint = colorRed
+		}
+		text = tint + text + colorReset
+	}
+	return text
+}
+
+// GroupSummary aggregates the results sharing a Group
+type GroupSummary struct {
+	Name       string  `json:"name"`
+	Results    int     `json:"results"`
+	Iterations int     `json:"iterations"`
+	Throughput float64 `json:"throughput_ops_sec"` // sum over the members
+}
+
+// summarizeGroups aggregates results by Group, in order of each group's
+// first result; ungrouped results are left out
+func summarizeGroups(results []BenchmarkResult) []GroupSummary {
+	var groups []GroupSummary
+	index := make(map[string]int)
+	for _, result := range results {
+		if result.Group == "" {
+			continue
+		}
+		i, ok := index[result.Group]
+		if !ok {
+			i = len(groups)
+			index[result.Group] = i
+			groups = append(groups, GroupSummary{Name: result.Group})
+		}
+		groups[i].Results++
+		groups[i].Iterations += result.Iterations
+		groups[i].Throughput += result.Throughput()
+	}
+	return groups
+}
+
+// printSummaryTable prints a summary row per result. Grouped results are
+// pulled together under a header where their group first appears,
+// indented, and followed by a row aggregating the group.
+func printSummaryTable(results []BenchmarkResult) {
+	fitSummaryNameWidth(results)
+	rule := strings.Repeat("=", summaryNameWidth+summaryRuleWidth)
+	fmt.Println(rule)
+	if summaryBaseline != nil {
+		fmt.Printf("%-*s %10s %15s %15s %14s %9s\n", summaryNameWidth, "Benchmark Name", "Iterations", "Mean Time", "Median Time", "Throughput", "vs base")
+	} else {
+		fmt.Printf("%-*s %10s %15s %15s %14s\n", summaryNameWidth, "Benchmark Name", "Iterations", "Mean Time", "Median Time", "Throughput")
+	}
+	fmt.Println(strings.Repeat("-", len(rule)))
+
+	groups := make(map[string]GroupSummary)
+	for _, group := range summarizeGroups(results) {
+		groups[group.Name] = group
+	}
+	printed := make(map[string]bool)
+	for _, result := range results {
+		if result.Group == "" {
+			result.PrintSummary()
+			continue
+		}
+		if printed[result.Group] {
+			continue
+		}
+		printed[result.Group] = true
+
+		fmt.Printf("[%s]\n", result.Group)
+		for _, member := range results {
+			if member.Group == result.Group {
+				member.printSummaryRow("  ")
+			}
+		}
+		group := groups[result.Group]
+		fmt.Printf("%-*s %10d %12s    %12s    %14.2f ops/sec\n", summaryNameWidth,
+			fmt.Sprintf("  (%d results total)", group.Results), group.Iterations, "", "", group.Throughput)
+	}
+}
+
+// rawNs makes formatDuration print plain nanoseconds (-raw-ns), for
+// scripts that parse the console output
+var rawNs bool
+
+// nsUnits are the units formatDuration scales to, each 1000 times the previous
+var nsUnits = []string{"ns", "µs", "ms", "s"}
+
+// formatDuration formats a duration in ns for people: scaled to the largest
+// unit it is at least 1 of, with three significant digits (0.52 ns,
+// 12.3 µs, 456 ms). A value that rounds up to 1000 moves to the next unit,
+// so it reads 1.00 ms rather than 1000 µs. Seconds aren't scaled further.
+// With rawNs set durations print as whole ns, as they did before.
+func formatDuration(ns float64) string {
+	if rawNs {
+		return fmt.Sprintf("%.0f ns", ns)
+	}
+	if math.IsNaN(ns) || math.IsInf(ns, 0) {
+		return "N/A"
+	}
+	sign := ""
+	if ns < 0 {
+		sign, ns = "-", -ns
+	}
+	unit := 0
+	for unit < len(nsUnits)-1 && ns >= 1000 {
+		ns /= 1000
+		unit++
+	}
+	text := formatSignificant(ns)
+	if text == "1000" && unit < len(nsUnits)-1 {
+		text, unit = "1.00", unit+1
+	}
+	return sign + text + " " + nsUnits[unit]
+}
+
+// formatSignificant formats a non-negative value with three significant
+// digits below 1000 and as a whole number above
+func formatSignificant(v float64) string {
+	switch {
+	case v == 0:
+		return "0"
+	case math.Round(v*100)/100 < 10:
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	case math.Round(v*10)/10 < 100:
+		return strconv.FormatFloat(v, 'f', 1, 64)
+	}
+	return strconv.FormatFloat(v, 'f', 0, 64)
+}
+
+// PrintDetailed prints detailed statistics
+func (br *BenchmarkResult) PrintDetailed() {
+	throughput := br.Throughput()
+	fmt.Printf("\n%s - Detailed Statistics:\n", br.Name)
+	fmt.Printf("  Iterations:    %d\n", br.Iterations)
+	if br.WarmupIterations > 0 {
+		fmt.Printf("  Warmup:        %d calls\n", br.WarmupIterations)
+	}
+	if br.OpsPerCall > 0 {
+		fmt.Printf("  Ops per call:  %.2f (%.0f ops measured)\n", br.OpsPerCall, br.TotalOps)
+	}
+	if br.BatchSize > 1 {
+		fmt.Printf("  Batch size:    %d calls per sample\n", br.BatchSize)
+	}
+	if br.TimerOverheadNs > 0 {
+		fmt.Printf("  Timer cost:    %s subtracted per sample\n", formatDuration(br.TimerOverheadNs))
+	}
+	if br.Stats.ConfidenceLevel > 0 {
+		fmt.Printf("  Mean:          %s (%g%% CI: [%s, %s])\n", formatDuration(br.Stats.MeanNs),
+			br.Stats.ConfidenceLevel*100, formatDuration(br.Stats.MeanCILowNs), formatDuration(br.Stats.MeanCIHighNs))
+	} else {
+		fmt.Printf("  Mean:          %s\n", formatDuration(br.Stats.MeanNs))
+	}
+	fmt.Printf("  Median:        %s\n", formatDuration(br.Stats.MedianNs))
+	fmt.Printf("  Min:           %s\n", formatDuration(br.Stats.MinNs))
+	fmt.Printf("  Max:           %s\n", formatDuration(br.Stats.MaxNs))
+	fmt.Printf("  Std Dev:       %s\n", formatDuration(br.Stats.StddevNs))
+	if br.Stats.RelativeStandardError > 0 {
+		fmt.Printf("  RSE of mean:   %.2f%%\n", br.Stats.RelativeStandardError*100)
+	}
+	if br.Stats.Estimated {
+		fmt.Println("  Percentiles:   P² estimates (streaming statistics)")
+	}
+	fmt.Printf("  95th pct:      %s\n", formatDuration(br.Stats.P95Ns))
+	fmt.Printf("  99th pct:      %s\n", formatDuration(br.Stats.P99Ns))
+	if br.Stats.TrimmedMeanNs > 0 {
+		fmt.Printf("  Trimmed mean:  %s (%d outliers removed)\n", formatDuration(br.Stats.TrimmedMeanNs), br.Stats.OutliersRemoved)
+	}
+	fmt.Printf("  Throughput:    %s ops/sec\n", formatThroughput(throughput))
+	if mbps := br.MBPerSec(); mbps > 0 {
+		fmt.Printf("  Bandwidth:     %.2f MB/s (%d bytes/op)\n", mbps, br.BytesPerOp)
+	}
+	if br.ThroughputP99 > 0 {
+		fmt.Printf("  Slow path:     %.2f ops/sec at p95, %.2f ops/sec at p99\n", br.ThroughputP95, br.ThroughputP99)
+	}
+	if br.AllocsMeasured {
+		fmt.Printf("  Alloc/op:      %.0f B\n", br.AllocBytesPerOp)
+		fmt.Printf("  Allocs/op:     %.2f\n", br.AllocsPerOp)
+	}
+	units := make([]string, 0, len(br.Metrics))
+	for unit := range br.Metrics {
+		units = append(units, unit)
+	}
+	sort.Strings(units)
+	for _, unit := range units {
+		fmt.Printf("  %-14s %.2f\n", unit+":", br.Metrics[unit])
+	}
+	if br.CPUTimeNs > 0 {
+		fmt.Printf("  CPU time:      %s (wall %s, parallelism %.2fx)\n",
+			formatDuration(br.CPUTimeNs), formatDuration(br.TotalTimeNs), br.Parallelism)
+		fmt.Printf("  CPU/op:        %s\n", formatDuration(br.CPUNsPerOp()))
+	}
+	if br.SingleSpikeDetected {
+		fmt.Printf("  Spike:         1 sample %.0fx median (+%s on mean)\n", br.SpikeRatio, formatDuration(br.SpikeMeanShiftNs))
+	}
+	if len(br.AllocSizes) > 0 {
+		fmt.Println("  Alloc sizes:")
+		for _, class := range br.AllocSizes {
+			label := fmt.Sprintf("<= %d B", class.MaxBytes)
+			if class.MaxBytes == 0 {
+				label = "> 32 KB"
+			}
+			fmt.Printf("    %-12s %10.2f/op (%5.1f%%)\n",
+				label, class.AllocsPerOp, class.AllocsPerOp/br.AllocsPerOp*100)
+		}
+	}
+	if br.HeapBallastBytes > 0 {
+		fmt.Printf("  Ballast:       %d MB\n", br.HeapBallastBytes>>20)
+	}
+	if br.GCCount > 0 || br.HeapBallastBytes > 0 {
+		fmt.Printf("  GC cycles:     %d (%.1f/sec)\n", br.GCCount, float64(br.GCCount)/(br.TotalTimeNs/1e9))
+	}
+	if br.GCPauseTotalNs > 0 {
+		fmt.Printf("  GC pauses:     %s total, %s longest, %.2f%% of the run\n",
+			formatDuration(float64(br.GCPauseTotalNs)), formatDuration(float64(br.GCPauseMaxNs)), float64(br.GCPauseTotalNs)/br.TotalTimeNs*100)
+	}
+	if br.CleanStats != nil {
+		fmt.Printf("  GC-free:       mean %s, p99 %s over %d samples\n",
+			formatDuration(br.CleanStats.MeanNs), formatDuration(br.CleanStats.P99Ns), br.Iterations-br.GCAffectedSamples)
+	}
+	if br.GCAffectedStats != nil {
+		fmt.Printf("  GC-affected:   mean %s, p99 %s over %d samples\n",
+			formatDuration(br.GCAffectedStats.MeanNs), formatDuration(br.GCAffectedStats.P99Ns), br.GCAffectedSamples)
+	}
+}
+
+// histogramBarWidth is the length of the longest PrintHistogram bar
+const histogramBarWidth = 40
+
+// PrintHistogram renders the latency distribution as an ASCII bar chart,
+// one line per bucket scaled to the fullest one
+func (br *BenchmarkResult) PrintHistogram() {
+	fmt.Printf("\n%s - Latency Histogram:\n", br.Name)
+	peak := 0
+	for _, bucket := range br.Stats.Buckets {
+		peak = max(peak, bucket.Count)
+	}
+	for _, bucket := range br.Stats.Buckets {
+		bar := bucket.Count * histogramBarWidth / max(peak, 1)
+		if bar == 0 && bucket.Count > 0 {
+			bar = 1 // keep lone tail samples visible
+		}
+		fmt.Printf("  %10s - %-10s %-*s %d\n",
+			formatDuration(bucket.LowerNs), formatDuration(bucket.UpperNs), histogramBarWidth, strings.Repeat("#", bar), bucket.Count)
+	}
+}
+
+// Reasons a benchmark stopped collecting measurements
+const (
+	// StopTimeBudget means the minimum benchmark time was reached
+	StopTimeBudget = "time_budget"
+	// StopMaxIterations means maxIterations samples were collected before the
+	// time budget, or the target RSE; the sample covers less wall time, or
+	// is less precise, than intended
+	StopMaxIterations = "max_iterations"
+	// StopCanceled means the run's context was canceled; the statistics
+	// cover the samples collected until then
+	StopCanceled = "canceled"
+	// StopMinSamples means the time budget ran out before minSamples samples
+	// were collected, so the run was extended until they were
+	StopMinSamples = "min_samples"
+	// StopSuiteBudget means the result's share of the suite's -budget ran
+	// out before minSamples samples were collected. The run isn't extended
+	// past a share, so its percentiles rest on fewer samples.
+	StopSuiteBudget = "suite_budget"
+	// StopTargetRSE means the relative standard error of the mean dropped
+	// to the runner's TargetRSE
+	StopTargetRSE = "target_rse"
+)
+
+// BenchmarkRunner provides utilities for running benchmarks
+type BenchmarkRunner struct {
+	warmupIterations   int
+	minIterations      int
+	minSamples         int
+	maxIterations      int
+	minBenchmarkTimeNs int64
+	confidenceLevel    float64
+
+	// trackAllocs samples runtime.MemStats around every measured pass.
+	// ReadMemStats stops the world, so it is off unless a benchmark needs it.
+	trackAllocs bool
+
+	// kind is recorded on results and applied while the benchmark runs:
+	// CPU-bound benchmarks are locked to their OS thread, IO-bound ones run
+	// with the GC disabled.
+	kind BenchmarkKind
+
+	// debugWarmup prints every warmup sample to stderr once warmup finishes
+	debugWarmup bool
+
+	// adaptiveWarmup keeps warming up past warmupIterations until the
+	// warmup samples stop changing; see warmupSettled
+	adaptiveWarmup bool
+
+	// cpuProfile is the path RunnerConfig.CPUProfile profiles the measured
+	// loop to
+	cpuProfile string
+
+	// timerOverhead is subtracted from every sample, clamped at zero; see
+	// RunnerConfig.SubtractTimerOverhead
+	timerOverhead time.Duration
+
+	// streamingStats keeps P² estimates instead of the samples; see
+	// RunnerConfig.StreamingStats
+	streamingStats bool
+
+	// separateGCSamples splits the statistics by GC interference; see
+	// RunnerConfig.SeparateGCSamples
+	separateGCSamples bool
+
+	// keepSamples copies the samples onto the result; see
+	// RunnerConfig.KeepSamples. Streaming statistics have none to keep.
+	keepSamples bool
+
+	// trimOutliers adds an IQR-trimmed mean and outlier count to the stats;
+	// the regular statistics keep describing the raw samples
+	trimOutliers bool
+
+	// trackGCPauses fills in the GC pause fields of the result from
+	// MemStats read before and after the measured loop
+	trackGCPauses bool
+
+	// allocSizes additionally records the size class distribution of
+	// allocations; it needs trackAllocs
+	allocSizes bool
+
+	// autoBatch times batches of calls instead of single calls, with the
+	// batch size calibrated per benchmark (see calibrateBatchSize). Each
+	// measurement is then the per-call average over one batch.
+	autoBatch bool
+
+	// dryRun makes Run return an empty, named result without calling the
+	// closure, so a benchmark's results can be enumerated without running it
+	dryRun bool
+
+	// budgeted is set when minBenchmarkTimeNs is a share of the suite's
+	// -budget, which minSamples may not extend a run past
+	budgeted bool
+
+	// filter, when set, makes Run skip results whose name doesn't match it
+	// the way dryRun does; see suiteOptions.filter
+	filter *regexp.Regexp
+
+	// ctx is the context Run uses; nil means context.Background()
+	ctx context.Context
+
+	// stream, when set, turns Run into a latency firehose for the one
+	// benchmark it names; every other benchmark is skipped like a dry run
+	stream *latencyStream
+
+	// BatchSize, when above 1, times that many calls per sample with a single
+	// clock reading pair, like testing.B's loop, so sub-microsecond closures
+	// aren't swamped by the timer; each sample is the per-call average. The
+	// default of 1 times every call, which latency percentiles need. autoBatch
+	// overrides it.
+	BatchSize int
+
+	// TargetRSE, when positive, replaces the time budget: the runner samples
+	// until the relative standard error of the mean drops to it (e.g. 0.01),
+	// up to maxIterations, so stable benchmarks finish quickly and noisy
+	// ones keep collecting. minSamples still applies.
+	TargetRSE float64
+
+	// HeapBallastBytes, when positive, keeps that much pointer-rich live heap
+	// around for the whole run and sets a soft memory limit just above it, so
+	// the collector runs often and every cycle has to mark the ballast. This
+	// exposes GC sensitivity that a small-heap benchmark hides.
+	HeapBallastBytes int
+
+	// WarmupTimeNs, when positive, replaces the warmup count (and adaptive
+	// warmup): the closure is called until that much wall-clock time has
+	// passed, so warmup takes as long for a 100 ns closure as for a 10 ms
+	// one. The warmup timings aren't kept then.
+	WarmupTimeNs int64
+
+	// GrowthFactor is how much each measured pass grows over the previous
+	// one while the time budget isn't met, starting from minIterations.
+	// Values near 1 approach the budget in finer steps at the cost of more
+	// passes; 0 means 2, doubling.
+	GrowthFactor float64
+
+	// MaxPassIterations, when positive, caps the samples of a single pass.
+	// Independently of it, a pass never takes more samples than the rate so
+	// far predicts are left in the time budget.
+	MaxPassIterations int
+}
+
+// RunnerConfig holds the iteration and time settings of a BenchmarkRunner
+type RunnerConfig struct {
+	WarmupIterations int           // untimed calls before measuring; 0 skips warmup
+	WarmupTime       time.Duration // warm up for this long instead of WarmupIterations calls; 0 uses the count
+	MinIterations    int           // samples taken in the first pass
+	MinSamples       int           // samples to collect even past the time budget, up to MaxIterations
+	MaxIterations    int           // cap on samples per benchmark
+	MinBenchmarkTime time.Duration // time budget each benchmark runs for
+	ConfidenceLevel  float64       // level of the mean's confidence interval, in (0, 1)
+	TrackAllocs      bool          // report allocations per op; stops the world around each pass
+	TrackGCPauses    bool          // report GC pause time over the measured loop; stops the world twice per benchmark
+	AdaptiveWarmup   bool          // warm up until stable instead of exactly WarmupIterations calls
+	BatchSize        int           // calls timed together per sample; 1 times every call
+	TargetRSE        float64       // stop once the mean's relative standard error reaches it; 0 uses MinBenchmarkTime
+	GrowthFactor     float64       // growth of each pass over the last while under the time budget; must be above 1
+
+	// StreamingStats computes the statistics on the fly instead of keeping
+	// every sample, for runs long enough that the samples would take
+	// hundreds of MB. Median, P95 and P99 are then P² estimates, and there
+	// is no histogram, outlier trimming or spike detection.
+	StreamingStats bool
+
+	// SeparateGCSamples checks, outside the timed region, whether a GC
+	// cycle completed during each sample, and reports statistics of the
+	// GC-free and the GC-affected samples besides the overall ones. It
+	// needs the samples, so streaming statistics ignore it.
+	SeparateGCSamples bool
+
+	// KeepSamples stores the raw samples on the result (Samples), which
+	// makes result files much larger but lets CompareSuites run a UTest
+	KeepSamples bool
+
+	// MaxPassIterations caps the samples of one measured pass; 0 leaves
+	// the pass size to GrowthFactor and the time budget
+	MaxPassIterations int
+
+	// SubtractTimerOverhead calibrates what starting and stopping the timer
+	// around an empty closure costs and subtracts it from every sample, for
+	// closures that only take tens of ns. Extra Stop/Start pairs inside a
+	// RunTimed closure are not accounted for.
+	SubtractTimerOverhead bool
+
+	// CPUProfile, when set, writes a pprof CPU profile of the measured loop
+	// to this path. Every measured benchmark rewrites it, so pair it with a
+	// filter selecting one. Profiling slows the loop down: profiled runs are
+	// for flamegraphs, not for their numbers.
+	CPUProfile string
+}
+
+// DefaultRunnerConfig returns the settings NewBenchmarkRunner uses
+func DefaultRunnerConfig() RunnerConfig {
+	return RunnerConfig{
+		WarmupIterations: 10,
+		MinIterations:    100,
+		MinSamples:       1000,
+		MaxIterations:    10000,
+		MinBenchmarkTime: 100 * time.Millisecond,
+		ConfidenceLevel:  defaultConfidenceLevel,
+		BatchSize:        1,
+		GrowthFactor:     2,
+	}
+}
+
+// Validate reports the first setting a runner can't work with
+func (c RunnerConfig) Validate() error {
+	switch {
+	case c.WarmupIterations < 0:
+		return fmt.Errorf("warmup iterations must not be negative, got %d", c.WarmupIterations)
+	case c.WarmupTime < 0:
+		return fmt.Errorf("warmup time must not be negative, got %s", c.WarmupTime)
+	case c.MinIterations <= 0:
+		return fmt.Errorf("min iterations must be positive, got %d", c.MinIterations)
+	case c.TargetRSE < 0:
+		return fmt.Errorf("target RSE must not be negative, got %g", c.TargetRSE)
+	case c.GrowthFactor <= 1:
+		return fmt.Errorf("growth factor must be above 1, got %g", c.GrowthFactor)
+	case c.MaxPassIterations < 0:
+		return fmt.Errorf("max pass iterations must not be negative, got %d", c.MaxPassIterations)
+	case c.BatchSize < 1:
+		return fmt.Errorf("batch size must be at least 1, got %d", c.BatchSize)
+	case c.MinSamples < 0:
+		return fmt.Errorf("min samples must not be negative, got %d", c.MinSamples)
+	case c.MaxIterations < c.MinIterations:
+		return fmt.Errorf("max iterations %d is below min iterations %d", c.MaxIterations, c.MinIterations)
+	case c.MinBenchmarkTime <= 0:
+		return fmt.Errorf("min benchmark time must be positive, got %s", c.MinBenchmarkTime)
+	case c.ConfidenceLevel <= 0 || c.ConfidenceLevel >= 1:
+		return fmt.Errorf("confidence level must be between 0 and 1, got %g", c.ConfidenceLevel)
+	}
+	return nil
+}
+
+// NewBenchmarkRunner creates a new benchmark runner with default settings
+func NewBenchmarkRunner() *BenchmarkRunner {
+	runner, _ := NewBenchmarkRunnerWithConfig(DefaultRunnerConfig())
+	return runner
+}
+
+// NewBenchmarkRunnerWithConfig creates a runner with the given settings,
+// e.g. no warmup and a short time budget for CI smoke runs
+func NewBenchmarkRunnerWithConfig(config RunnerConfig) (*BenchmarkRunner, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid runner config: %w", err)
+	}
+	runner := &BenchmarkRunner{
+		warmupIterations:   config.WarmupIterations,
+		minIterations:      config.MinIterations,
+		minSamples:         config.MinSamples,
+		maxIterations:      config.MaxIterations,
+		minBenchmarkTimeNs: config.MinBenchmarkTime.Nanoseconds(),
+		confidenceLevel:    config.ConfidenceLevel,
+		trackAllocs:        config.TrackAllocs,
+		trackGCPauses:      config.TrackGCPauses,
+		adaptiveWarmup:     config.AdaptiveWarmup,
+		cpuProfile:         config.CPUProfile,
+		BatchSize:          config.BatchSize,
+		TargetRSE:          config.TargetRSE,
+		GrowthFactor:       config.GrowthFactor,
+		WarmupTimeNs:       config.WarmupTime.Nanoseconds(),
+		MaxPassIterations:  config.MaxPassIterations,
+		streamingStats:     config.StreamingStats,
+		keepSamples:        config.KeepSamples,
+		separateGCSamples:  config.SeparateGCSamples,
+	}
+	if config.SubtractTimerOverhead {
+		runner.timerOverhead = calibrateTimerOverhead()
+	}
+	return runner, nil
+}
+
+// TimerOverhead is the calibrated per-sample timer cost the runner subtracts,
+// 0 unless RunnerConfig.SubtractTimerOverhead is set
+func (br *BenchmarkRunner) TimerOverhead() time.Duration {
+	return br.timerOverhead
+}
+
+// ballastObjectSize keeps ballast objects small so the collector has many
+// pointers to follow, like a real server heap
+const ballastObjectSize = 128
+
+// newHeapBallast allocates roughly size bytes of live, pointer-rich heap
+func newHeapBallast(size int) []*[ballastObjectSize]byte {
+	ballast := make([]*[ballastObjectSize]byte, size/ballastObjectSize)
+	for i := range ballast {
+		ballast[i] = new([ballastObjectSize]byte)
+	}
+	return ballast
+}
+
+// gcCounter reads the number of completed GC cycles. Unlike
+// runtime.ReadMemStats it does not stop the world, and reusing one counter
+// doesn't allocate, so it can be read between timed samples.
+type gcCounter []metrics.Sample
+
+func newGCCounter() gcCounter {
+	return gcCounter{{Name: "/gc/cycles/total:gc-cycles"}}
+}
+
+// cycles returns the number of GC cycles completed so far
+func (c gcCounter) cycles() uint64 {
+	metrics.Read(c)
+	return c[0].Value.Uint64()
+}
+
+// gcPauseMax returns the longest GC pause of the cycles that completed
+// between two MemStats readings. MemStats keeps the last 256 pauses only,
+// so when more cycles ran it is the longest of the most recent 256.
+func gcPauseMax(before, after *runtime.MemStats) uint64 {
+	ring := uint32(len(after.PauseNs))
+	cycles := min(int(after.NumGC-before.NumGC), int(ring))
+	var longest uint64
+	for i := 0; i < cycles; i++ {
+		// Cycle n's pause is at PauseNs[(n+255)%256]
+		longest = max(longest, after.PauseNs[(after.NumGC-uint32(i)+ring-1)%ring])
+	}
+	return longest
+}
+
+// allocSizeClasses turns per-size-class malloc counts into per-op figures,
+// skipping empty classes. Mallocs not attributed to any class are large
+// objects and are reported last with MaxBytes 0.
+func allocSizeClasses(classes []struct {
+	Size    uint32
+	Mallocs uint64
+	Frees   uint64
+}, counts []uint64, total uint64, calls float64) []AllocSizeClass {
+	var result []AllocSizeClass
+	var classified uint64
+	for i, count := range counts {
+		if count == 0 {
+			continue
+		}
+		classified += count
+		result = append(result, AllocSizeClass{MaxBytes: classes[i].Size, AllocsPerOp: float64(count) / calls})
+	}
+	if total > classified {
+		result = append(result, AllocSizeClass{AllocsPerOp: float64(total-classified) / calls})
+	}
+	return result
+}
+
+// clockEpoch anchors nowNanos; only its monotonic reading is used
+var clockEpoch = time.Now()
+
+// nowNanos returns nanoseconds since clockEpoch on the runtime's monotonic
+// clock, which wall clock steps (NTP, manual changes) can't move. Its
+// resolution is the platform's: about 1ns on Linux and macOS, but far
+// coarser on some systems (up to ~0.5µs on Windows), which is what
+// clockResolution measures.
+func nowNanos() int64 {
+	return int64(time.Since(clockEpoch))
+}
+
+// coarseClockThreshold is the clock resolution above which the suite warns
+// that short samples will mostly show clock granularity
+const coarseClockThreshold = 50 * time.Nanosecond
+
+// clockResolution estimates the smallest observable step of nowNanos by
+// reading the clock until it changes, keeping the minimum over several tries
+var clockResolution = sync.OnceValue(func() time.Duration {
+	best := time.Duration(math.MaxInt64)
+	for i := 0; i < 100; i++ {
+		start := nowNanos()
+		step := nowNanos() - start
+		for step == 0 {
+			step = nowNanos() - start
+		}
+		if step := time.Duration(step); step < best {
+			best = step
+		}
+	}
+	return best
+})
+
+const (
+	// batchResolutionFactor is how many clock ticks one timed batch must span
+	batchResolutionFactor = 100
+	// maxBatchSize bounds calibration for closures that are effectively free
+	maxBatchSize = 1 << 20
+)
+
+// calibrateBatchSize doubles the batch size until a single timed batch takes
+// at least batchResolutionFactor clock ticks, so per-call averages are not
+// quantized by the clock. Calibration runs act as extra warmup.
+func calibrateBatchSize(fn func()) int {
+	target := batchResolutionFactor * clockResolution()
+	batch := 1
+	for ; batch < maxBatchSize; batch *= 2 {
+		start := time.Now()
+		for i := 0; i < batch; i++ {
+			fn()
+		}
+		if time.Since(start) >= target {
+			break
+		}
+	}
+	return batch
+}
+
+// timerOverheadSamples is how many empty samples calibrateTimerOverhead times
+const timerOverheadSamples = 10000
+
+// timerOverheadProbe is the empty closure calibrateTimerOverhead times. As a
+// package variable it is called indirectly, like a benchmark's closure.
+var timerOverheadProbe = func(*Timer) {}
+
+// calibrateTimerOverhead returns the median time a sample of an empty closure
+// takes, i.e. the cost of the Timer's Start/Stop pair and the call itself
+var calibrateTimerOverhead = sync.OnceValue(func() time.Duration {
+	var timer Timer
+	samples := make([]float64, timerOverheadSamples)
+	for i := range samples {
+		timer.reset()
+		timer.Start()
+		timerOverheadProbe(&timer)
+		timer.Stop()
+		samples[i] = float64(timer.elapsed)
+	}
+	sort.Float64s(samples)
+	return time.Duration(samples[len(samples)/2])
+})
+
+// newBenchmarkRunnerForKind creates a runner with defaults suited to kind
+func newBenchmarkRunnerForKind(kind BenchmarkKind) *BenchmarkRunner {
+	runner := NewBenchmarkRunner()
+	runner.kind = kind
+	switch kind {
+	case KindIOBound:
+		// IO-style benchmarks are latency dominated and noisier, give them longer
+		runner.minBenchmarkTimeNs *= 2
+	case KindMemoryBound:
+		runner.trackAllocs = true
+	}
+	return runner
+}
+
+// Run executes a benchmark function with the given name. It stops early,
+// with the samples collected so far, when the runner's context is canceled.
+func (br *BenchmarkRunner) Run(name string, benchmarkFunc func()) BenchmarkResult {
+	ctx := br.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	result, _ := br.RunContext(ctx, name, benchmarkFunc)
+	return result
+}
+
+// Timer is handed to RunTimed closures so they can exclude setup from the
+// measured time, like testing.B's StopTimer/StartTimer. It is running when
+// the closure is called; a sample is the time it spent running. Allocations
+// made while it is stopped are still counted.
+type Timer struct {
+	running bool
+	start   int64 // nowNanos reading
+	elapsed time.Duration
+}
+
+// Start resumes timing; it does nothing if the timer is running
+func (t *Timer) Start() {
+	if !t.running {
+		t.running = true
+		t.start = nowNanos()
+	}
+}
+
+// Stop pauses timing; it does nothing if the timer is stopped
+func (t *Timer) Stop() {
+	if t.running {
+		t.elapsed += time.Duration(nowNanos() - t.start)
+		t.running = false
+	}
+}
+
+// reset stops the timer and clears the accumulated time for a new sample
+func (t *Timer) reset() {
+	t.running = false
+	t.elapsed = 0
+}
+
+// RunTimed is Run for closures that control their own timing through t,
+// e.g. stopping it while rebuilding a buffer the measured code consumes.
+// Warmup and -auto-batch calibration time whole calls, setup included.
+func (br *BenchmarkRunner) RunTimed(name string, benchmarkFunc func(t *Timer)) BenchmarkResult {
+	ctx := br.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	result, _ := br.runTimed(ctx, name, benchmarkFunc)
+	return result
+}
+
+// RunN is Run for closures that do several logical operations per call,
+// e.g. processing a batch of items; fn returns how many it did. Throughput
+// then counts those operations instead of calls.
+func (br *BenchmarkRunner) RunN(name string, benchmarkFunc func() int) BenchmarkResult {
+	var ops, calls int
+	result := br.Run(name, func() {
+		ops += benchmarkFunc()
+		calls++
+	})
+	// Warmup calls are counted too, which only matters if the op count varies
+	if calls > 0 && ops > 0 {
+		result.OpsPerCall = float64(ops) / float64(calls)
+		result.TotalOps = result.OpsPerCall * float64(result.Iterations*max(result.BatchSize, 1))
+		result.setTailThroughput()
+	}
+	return result
+}
+
+// parallelCallsPerWorker is how often every RunParallel worker calls fn per
+// sample, so releasing and collecting the workers is amortized
+const parallelCallsPerWorker = 100
+
+// RunParallel measures fn called concurrently from parallelism goroutines,
+// GOMAXPROCS of them when parallelism is 0, in the spirit of
+// testing.B.RunParallel. The workers are started and parked before
+// measuring; a sample releases all of them and ends when the last one has
+// made parallelCallsPerWorker calls. TotalOps sums the calls of every
+// worker, so Throughput is the aggregate rate.
+func (br *BenchmarkRunner) RunParallel(name string, parallelism int, fn func()) BenchmarkResult {
+	return br.runParallel(name, parallelism, func(int) {
+		for i := 0; i < parallelCallsPerWorker; i++ {
+			fn()
+		}
+	})
+}
+
+// runParallel is RunParallel for work that needs the index of its worker,
+// e.g. to pick a shard. Every sample calls work once per worker, and work
+// makes its parallelCallsPerWorker calls itself.
+func (br *BenchmarkRunner) runParallel(name string, parallelism int, work func(worker int)) BenchmarkResult {
+	if br.skipsAll(name) {
+		return br.Run(name, nil) // without starting the workers
+	}
+	if parallelism <= 0 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	pool := newParallelWorkers(parallelism, work)
+	defer pool.close()
+	return br.RunN(name, func() int {
+		pool.run()
+		return parallelism * parallelCallsPerWorker
+	})
+}
+
+// RunGroup is Run for a result that belongs to group, e.g. one size of a
+// sweep; the summary lists the members of a group together
+func (br *BenchmarkRunner) RunGroup(group, name string, benchmarkFunc func()) BenchmarkResult {
+	result := br.Run(name, benchmarkFunc)
+	result.Group = group
+	return result
+}
+
+// Metrics RunAB records on the B result
+const (
+	abDeltaMetric  = "vs A %"
+	abPValueMetric = "p"
+)
+
+// RunAB measures a and b interleaved, one call of each per round, so drift
+// over the run such as thermal throttling or a background job hits both
+// alike instead of whichever ran second. Rounds alternate the order (ABBA...)
+// so what one call leaves behind, warm caches or GC debt, lands on each side
+// equally often. The results are name/A and name/B in group name, sharing
+// twice the time budget; both report the wall time of the interleaved loop
+// as TotalTimeNs. B's result records its mean change against A and the
+// p-value of a paired t-test over the rounds as metrics.
+//
+// Warmup, batching, the timer overhead, streaming statistics and the
+// latency stream apply as they do to Run. Allocations are counted over
+// separate untimed calls of each side, as a round's can't be split between
+// them. TargetRSE, CPU profiles, heap ballast, GC pause tracking and GC
+// sample splitting don't apply; a warning says so when they are set.
+func (br *BenchmarkRunner) RunAB(name string, a, b func()) (BenchmarkResult, BenchmarkResult) {
+	resultA := BenchmarkResult{Name: name + "/A", Kind: br.kind, Group: name}
+	resultB := BenchmarkResult{Name: name + "/B", Kind: br.kind, Group: name}
+	if br.skips(resultA.Name) && br.skips(resultB.Name) {
+		return resultA, resultB
+	}
+	if br.stream != nil {
+		// A latency stream times the side it names on its own
+		if br.stream.name == resultA.Name {
+			br.stream.run(a)
+		} else {
+			br.stream.run(b)
+		}
+		return resultA, resultB
+	}
+	if ignored := br.abIgnoredOptions(); len(ignored) > 0 {
+		fmt.Fprintf(os.Stderr, "warning: %s: A/B runs ignore %s\n", name, strings.Join(ignored, ", "))
+	}
+	ctx := br.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	switch br.kind {
+	case KindCPUBound:
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+	case KindIOBound:
+		defer debug.SetGCPercent(debug.SetGCPercent(-1))
+	}
+
+	warmup, warmupCalls := br.warmUp(ctx, name, func() {
+		a()
+		b()
+	})
+	batch := max(br.batchSize(a), br.batchSize(b))
+	overhead := br.timerOverhead.Nanoseconds()
+	for _, result := range []*BenchmarkResult{&resultA, &resultB} {
+		result.WarmupNs = warmup
+		result.WarmupIterations = warmupCalls
+		result.TimerOverheadNs = float64(overhead)
+		if br.autoBatch || batch > 1 {
+			result.BatchSize = batch
+		}
+	}
+
+	var samplesA, samplesB []float64
+	var streamA, streamB *streamingStats
+	if br.streamingStats {
+		streamA, streamB = newStreamingStats(), newStreamingStats()
+	}
+	var momentsA, momentsB, diffs runningStats
+	// One clock reading ends the first batch and starts the second, so both
+	// samples carry the same timer cost
+	start := nowNanos()
+	elapsed := int64(0)
+	for round := 0; round < br.maxIterations && ctx.Err() == nil; round++ {
+		if elapsed >= 2*br.minBenchmarkTimeNs && (round >= br.minSamples || br.budgeted) {
+			break
+		}
+		first, second := a, b
+		if round%2 == 1 {
+			first, second = b, a
+		}
+		t0 := nowNanos()
+		for i := 0; i < batch; i++ {
+			first()
+		}
+		t1 := nowNanos()
+		for i := 0; i < batch; i++ {
+			second()
+		}
+		t2 := nowNanos()
+		elapsed = t2 - start
+
+		durationA, durationB := t1-t0, t2-t1
+		if round%2 == 1 {
+			durationA, durationB = durationB, durationA
+		}
+		sampleA := float64(max(durationA-overhead, 0)) / float64(batch)
+		sampleB := float64(max(durationB-overhead, 0)) / float64(batch)
+		if br.streamingStats {
+			streamA.add(sampleA)
+			streamB.add(sampleB)
+		} else {
+			samplesA = append(samplesA, sampleA)
+			samplesB = append(samplesB, sampleB)
+		}
+		momentsA.add(sampleA)
+		momentsB.add(sampleB)
+		diffs.add(sampleB - sampleA)
+	}
+
+	for _, r := range []struct {
+		result  *BenchmarkResult
+		fn      func()
+		samples []float64
+		stream  *streamingStats
+		moments runningStats
+	}{{&resultA, a, samplesA, streamA, momentsA}, {&resultB, b, samplesB, streamB, momentsB}} {
+		r.result.Iterations = r.moments.n
+		r.result.TotalTimeNs = float64(elapsed)
+		r.result.MonotonicTiming = true
+		r.result.StopReason = StopTimeBudget
+		if ctx.Err() != nil {
+			r.result.StopReason = StopCanceled
+		} else if r.moments.n >= br.maxIterations {
+			r.result.StopReason = StopMaxIterations
+		} else if br.budgeted && r.moments.n < br.minSamples {
+			r.result.StopReason = StopSuiteBudget
+		}
+		if r.stream != nil {
+			r.result.Stats = r.stream.stats(r.moments, br.confidenceLevel)
+			r.result.setTailThroughput()
+		} else {
+			br.setStats(r.result, r.samples)
+		}
+		if br.trackAllocs && r.moments.n > 0 && ctx.Err() == nil {
+			br.countAllocs(r.result, r.fn, min(r.moments.n*batch, abAllocCalls))
+		}
+	}
+
+	if resultA.Stats.MeanNs > 0 && diffs.n > 1 {
+		resultB.SetMetric(abDeltaMetric, (resultB.Stats.MeanNs/resultA.Stats.MeanNs-1)*100)
+		resultB.SetMetric(abPValueMetric, diffs.tTest())
+	}
+	return resultA, resultB
+}
+
+// abIgnoredOptions returns the set options RunAB doesn't apply
+func (br *BenchmarkRunner) abIgnoredOptions() []string {
+	var ignored []string
+	for _, option := range []struct {
+		name string
+		set  bool
+	}{
+		{"TargetRSE", br.TargetRSE > 0},
+		{"CPUProfile", br.cpuProfile != ""},
+		{"HeapBallastBytes", br.HeapBallastBytes > 0},
+		{"TrackGCPauses", br.trackGCPauses},
+		{"SeparateGCSamples", br.separateGCSamples},
+	} {
+		if option.set {
+			ignored = append(ignored, option.name)
+		}
+	}
+	return ignored
+}
+
+// tTest returns the two-sided p-value of a t-test of the mean of the
+// samples being zero, e.g. of the differences of paired samples. It needs
+// at least 2 samples.
+func (rs runningStats) tTest() float64 {
+	sd := math.Sqrt(rs.m2 / float64(rs.n-1))
+	if sd == 0 {
+		if rs.mean == 0 {
+			return 1
+		}
+		return 0
+	}
+	t := math.Abs(rs.mean) / (sd / math.Sqrt(float64(rs.n)))
+	if rs.n >= largeSampleSize {
+		return math.Erfc(t / math.Sqrt2)
+	}
+	return 1 - studentTCoverage(t, rs.n-1)
+}
+
+// RunBytes is Run for closures that move bytesPerOp bytes per call, e.g.
+// copying or checksumming a buffer; the result then also reports MB/s
+func (br *BenchmarkRunner) RunBytes(name string, bytesPerOp int, benchmarkFunc func()) BenchmarkResult {
+	result := br.Run(name, benchmarkFunc)
+	result.BytesPerOp = bytesPerOp
+	return result
+}
+
+// RunSweep runs fn once per size, as "name/size=<size>", for scaling
+// studies. The results form the group name and carry their size in Size.
+func (br *BenchmarkRunner) RunSweep(name string, sizes []int, fn func(size int)) []BenchmarkResult {
+	return br.RunSweepWithSetup(name, sizes, func(size int) (func(), func()) {
+		return func() { fn(size) }, nil
+	})
+}
+
+// RunSweepWithSetup is RunSweep for sizes that need a fixture, e.g. an input
+// of that size or started workers. setup builds it and returns the closure
+// to measure and a teardown, which may be nil. Neither runs for the sizes
+// the runner skips, such as in a dry run.
+func (br *BenchmarkRunner) RunSweepWithSetup(name string, sizes []int, setup func(size int) (fn func(), teardown func())) []BenchmarkResult {
+	return br.runTimedSweep(name, sizes, func(size int) (func(*Timer), func()) {
+		fn, teardown := setup(size)
+		return func(*Timer) { fn() }, teardown
+	})
+}
+
+// runTimedSweep is RunSweepWithSetup for closures that control their own
+// timing, as with RunTimed
+func (br *BenchmarkRunner) runTimedSweep(name string, sizes []int, setup func(size int) (fn func(t *Timer), teardown func())) []BenchmarkResult {
+	results := make([]BenchmarkResult, 0, len(sizes))
+	for _, size := range sizes {
+		sizeName := fmt.Sprintf("%s/size=%d", name, size)
+		var fn func(*Timer)
+		var teardown func()
+		if !br.skips(sizeName) {
+			fn, teardown = setup(size)
+		}
+		result := br.RunTimed(sizeName, fn)
+		if teardown != nil {
+			teardown()
+		}
+		result.Group = name
+		result.Size = size
+		results = append(results, result)
+	}
+	return results
+}
+
+// Metrics RunScaling records on each level's result
+const (
+	speedupMetric    = "speedup"
+	efficiencyMetric = "efficiency"
+)
+
+// scalingEfficiencyFloor is the parallel efficiency below which a workload
+// counts as no longer scaling
+const scalingEfficiencyFloor = 0.70
+
+// RunScaling runs fn through RunParallel at each parallelism level, as
+// "name/p=<level>" in group name, and records every level's speedup over
+// level 1 and its parallel efficiency (speedup / level) as metrics. Level 1
+// is added when levels lacks it, since both are relative to it.
+func (br *BenchmarkRunner) RunScaling(name string, levels []int, fn func()) []BenchmarkResult {
+	return br.runScaling(name, levels, func(int) {
+		for i := 0; i < parallelCallsPerWorker; i++ {
+			fn()
+		}
+	})
+}
+
+// runScaling is RunScaling through runParallel, for work that needs the
+// index of its worker
+func (br *BenchmarkRunner) runScaling(name string, levels []int, work func(worker int)) []BenchmarkResult {
+	levels = append([]int{1}, levels...)
+	slices.Sort(levels)
+	levels = slices.Compact(levels)
+
+	results := make([]BenchmarkResult, 0, len(levels))
+	var baseThroughput float64
+	for _, level := range levels {
+		if level < 1 {
+			continue
+		}
+		result := br.runParallel(fmt.Sprintf("%s/p=%d", name, level), level, work)
+		result.Group = name
+		result.Workers = level
+		throughput := result.Throughput()
+		if level == 1 {
+			baseThroughput = throughput
+		}
+		if baseThroughput > 0 && throughput > 0 {
+			speedup := throughput / baseThroughput
+			result.SetMetric(speedupMetric, speedup)
+			result.SetMetric(efficiencyMetric, speedup/float64(level))
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// ScalingLimit returns the lowest parallelism level among RunScaling results
+// whose efficiency falls below scalingEfficiencyFloor, 0 if none does
+func ScalingLimit(results []BenchmarkResult) int {
+	for _, result := range results {
+		if efficiency, ok := result.Metrics[efficiencyMetric]; ok && efficiency < scalingEfficiencyFloor {
+			return result.Workers
+		}
+	}
+	return 0
+}
+
+// RunWithSetup is Run for benchmarks that need a fixture, e.g. an open file
+// or a prebuilt input, that shouldn't be timed or rebuilt per call. setup
+// runs once before warmup, every call of fn gets its fixture, and teardown,
+// if not nil, runs after the last measurement. Neither runs when the
+// runner skips name.
+func (br *BenchmarkRunner) RunWithSetup(name string, setup func() interface{}, fn func(fixture interface{}), teardown func(fixture interface{})) BenchmarkResult {
+	if br.skips(name) {
+		return BenchmarkResult{Name: name, Kind: br.kind}
+	}
+	fixture := setup()
+	if teardown != nil {
+		defer teardown(fixture)
+	}
+	return br.Run(name, func() { fn(fixture) })
+}
+
+// skips reports whether Run returns an empty result for name without
+// calling the closure: in a dry run, and for results the filter or a
+// latency stream for another benchmark leave out
+func (br *BenchmarkRunner) skips(name string) bool {
+	return br.dryRun || br.stream != nil && name != br.stream.name || br.filter != nil && !br.filter.MatchString(name)
+}
+
+// skipsAll reports whether the runner skips every one of names, so a
+// benchmark can skip the setup they share, e.g. starting goroutines, and
+// only name its results with Run(name, nil)
+func (br *BenchmarkRunner) skipsAll(names ...string) bool {
+	for _, name := range names {
+		if !br.skips(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// RunContext is Run with cancellation: ctx is checked between samples, and
+// once it is done the partial result is returned together with ctx.Err().
+// The statistics are still calculated over the partial sample.
+func (br *BenchmarkRunner) RunContext(ctx context.Context, name string, benchmarkFunc func()) (BenchmarkResult, error) {
+	return br.runTimed(ctx, name, func(*Timer) { benchmarkFunc() })
+}
+
+// Adaptive warmup settles once the mean of the last adaptiveWarmupWindow
+// samples is within adaptiveWarmupTolerance of the window before it
+const (
+	adaptiveWarmupWindow        = 10
+	adaptiveWarmupTolerance     = 0.02
+	maxAdaptiveWarmupIterations = 1000
+)
+
+// warmupSettled reports whether the warmup samples have stopped changing
+func warmupSettled(warmup []float64) bool {
+	n := len(warmup)
+	if n < 2*adaptiveWarmupWindow {
+		return false
+	}
+	mean := func(samples []float64) float64 {
+		sum := 0.0
+		for _, s := range samples {
+			sum += s
+		}
+		return sum / float64(len(samples))
+	}
+	previous := mean(warmup[n-2*adaptiveWarmupWindow : n-adaptiveWarmupWindow])
+	last := mean(warmup[n-adaptiveWarmupWindow:])
+	return math.Abs(last-previous) <= adaptiveWarmupTolerance*previous
+}
+
+// runTimed runs measure and turns a panic in the benchmark into a failed
+// result, so the rest of the suite still runs. Panics in goroutines the
+// benchmark starts itself can't be caught this way.
+func (br *BenchmarkRunner) runTimed(ctx context.Context, name string, timedFunc func(t *Timer)) (result BenchmarkResult, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "benchmark %s panicked: %v\n%s", name, r, debug.Stack())
+			result = BenchmarkResult{Name: name, Kind: br.kind, Failed: true, Error: fmt.Sprint(r)}
+			err = fmt.Errorf("benchmark %s panicked: %v", name, r)
+		}
+	}()
+	return br.measure(ctx, name, timedFunc)
+}
+
+// benchmarkProgress is the state of the benchmark currently in measure,
+// for the SIGUSR1 status dump. The loop updates it with atomic stores, so
+// reading it neither blocks nor allocates in the measured code.
+type benchmarkProgress struct {
+	name    string
+	start   time.Time
+	samples atomic.Int64
+	mean    atomic.Uint64 // math.Float64bits of the running mean
+}
+
+// currentProgress is swapped to a new benchmarkProgress as each benchmark starts
+var currentProgress atomic.Pointer[benchmarkProgress]
+
+func (p *benchmarkProgress) update(running runningStats) {
+	p.samples.Store(int64(running.n))
+	p.mean.Store(math.Float64bits(running.mean))
+}
+
+// printProgress writes one line on the running benchmark to w
+func printProgress(w io.Writer) {
+	p := currentProgress.Load()
+	if p == nil {
+		fmt.Fprintln(w, "status: no benchmark running")
+		return
+	}
+	elapsed := time.Since(p.start).Round(time.Millisecond)
+	samples := p.samples.Load()
+	if samples == 0 {
+		fmt.Fprintf(w, "status: %s: warming up, %s elapsed\n", p.name, elapsed)
+		return
+	}
+	fmt.Fprintf(w, "status: %s: %d samples, %s elapsed, running mean %s\n",
+		p.name, samples, elapsed, formatDuration(math.Float64frombits(p.mean.Load())))
+}
+
+// nextPassIterations grows a pass of iterations samples by GrowthFactor,
+// by at least one sample
+func (br *BenchmarkRunner) nextPassIterations(iterations int) int {
+	factor := br.GrowthFactor
+	if factor <= 1 {
+		factor = 2
+	}
+	return max(int(math.Ceil(float64(iterations)*factor)), iterations+1)
+}
+
+// budgetedPass caps a pass so it doesn't overshoot the budget by up to a
+// whole pass: to the samples the rate so far predicts are left, plus one.
+// Before the clock has ticked there is no rate, and pass is kept.
+func budgetedPass(pass, samples int, elapsed, budget int64) int {
+	if samples == 0 || elapsed <= 0 {
+		return pass
+	}
+	left := float64(budget-elapsed) * float64(samples) / float64(elapsed)
+	if left < float64(pass) {
+		return int(left) + 1
+	}
+	return pass
+}
+
+// measure is the measurement loop behind Run, RunContext and RunTimed
+func (br *BenchmarkRunner) measure(ctx context.Context, name string, timedFunc func(t *Timer)) (BenchmarkResult, error) {
+	if br.skips(name) {
+		return BenchmarkResult{Name: name, Kind: br.kind}, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return BenchmarkResult{Name: name, Kind: br.kind, StopReason: StopCanceled}, err
+	}
+
+	progress := &benchmarkProgress{name: name, start: time.Now()}
+	currentProgress.Store(progress)
+	defer currentProgress.CompareAndSwap(progress, nil)
+
+	switch br.kind {
+	case KindCPUBound:
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+	case KindIOBound:
+		defer debug.SetGCPercent(debug.SetGCPercent(-1))
+	}
+
+	if br.HeapBallastBytes > 0 {
+		ballast := newHeapBallast(br.HeapBallastBytes)
+		defer runtime.KeepAlive(ballast)
+
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		limit := int64(mem.HeapAlloc) + int64(br.HeapBallastBytes)/4
+		defer debug.SetMemoryLimit(debug.SetMemoryLimit(limit))
+	}
+
+	var timer Timer
+	benchmarkFunc := func() {
+		timer.reset()
+		timer.Start()
+		timedFunc(&timer)
+	}
+
+	warmup, warmupCalls := br.warmUp(ctx, name, benchmarkFunc)
+	if br.stream != nil {
+		br.stream.run(benchmarkFunc)
+		return BenchmarkResult{Name: name, Kind: br.kind, WarmupNs: warmup, WarmupIterations: warmupCalls}, nil
+	}
+
+	batch := br.batchSize(benchmarkFunc)
+
+	result := BenchmarkResult{
+		Name:             name,
+		Stats:            BenchmarkStats{},
+		Kind:             br.kind,
+		WarmupNs:         warmup,
+		WarmupIterations: warmupCalls,
+		HeapBallastBytes: br.HeapBallastBytes,
+		TimerOverheadNs:  float64(br.timerOverhead.Nanoseconds()),
+	}
+	if br.autoBatch || batch > 1 {
+		result.BatchSize = batch
+	}
+
+	if br.cpuProfile != "" {
+		stopProfile, err := startCPUProfile(br.cpuProfile)
+		if err != nil {
+			return BenchmarkResult{Name: name, Kind: br.kind, Failed: true, Error: err.Error()}, err
+		}
+		defer func() {
+			if err := stopProfile(); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: %s: %v\n", name, err)
+			}
+		}()
+	}
+
+	var measurements []float64
+	var allocBytes, allocs uint64
+	var bySize [len(runtime.MemStats{}.BySize)]uint64
+	var memBefore, memAfter runtime.MemStats
+	var gcStatsBefore runtime.MemStats
+	if br.trackGCPauses {
+		runtime.ReadMemStats(&gcStatsBefore)
+	}
+	gc := newGCCounter()
+	gcBefore := gc.cycles()
+	cpuBefore := processCPUTime()
+	totalStart := time.Now()
+	iterations := br.minIterations
+	elapsed := int64(0)
+	extended := false
+	var running runningStats
+	rseReached := false
+	var stream *streamingStats
+	if br.streamingStats {
+		stream = newStreamingStats()
+	}
+	gcSplit := br.separateGCSamples && stream == nil
+	var gcAffected []int // indexes into measurements
+
+	for running.n < br.maxIterations && ctx.Err() == nil {
+		// Percentiles of a handful of samples mean nothing, so a benchmark
+		// slow enough to exhaust its time budget early keeps going until
+		// minSamples, unless the budget is a share of the suite's
+		if br.TargetRSE > 0 {
+			if running.n >= br.minSamples && running.rse() <= br.TargetRSE {
+				rseReached = true
+				break
+			}
+		} else if elapsed >= br.minBenchmarkTimeNs && (running.n >= br.minSamples || br.budgeted) {
+			break
+		}
+		pass := min(iterations, br.maxIterations-running.n)
+		if br.MaxPassIterations > 0 {
+			pass = min(pass, br.MaxPassIterations)
+		}
+		if br.TargetRSE == 0 && elapsed >= br.minBenchmarkTimeNs {
+			extended = true
+			pass = min(pass, br.minSamples-running.n)
+		} else if br.TargetRSE == 0 {
+			pass = budgetedPass(pass, running.n, elapsed, br.minBenchmarkTimeNs)
+		}
+		pass = max(pass, 1)
+		if br.trackAllocs {
+			// Grow up front so appending measurements is not counted as benchmark allocations
+			if stream == nil {
+				measurements = slices.Grow(measurements, pass)
+			}
+			runtime.ReadMemStats(&memBefore)
+		}
+
+		for i := 0; i < pass && ctx.Err() == nil; i++ {
+			var cycles uint64
+			if gcSplit {
+				cycles = gc.cycles()
+			}
+			timer.reset()
+			timer.Start()
+			for j := 0; j < batch; j++ {
+				timedFunc(&timer)
+			}
+			timer.Stop()
+			duration := timer.elapsed
+			if duration < 0 {
+				result.NegativeSamples++
+				continue
+			}
+			duration = max(duration-br.timerOverhead, 0)
+			sample := float64(duration.Nanoseconds()) / float64(batch)
+			if stream != nil {
+				stream.add(sample)
+			} else {
+				measurements = append(measurements, sample)
+				if gcSplit && gc.cycles() != cycles {
+					gcAffected = append(gcAffected, len(measurements)-1)
+				}
+			}
+			running.add(sample)
+			progress.update(running)
+		}
+
+		if br.trackAllocs {
+			runtime.ReadMemStats(&memAfter)
+			allocBytes += memAfter.TotalAlloc - memBefore.TotalAlloc
+			allocs += memAfter.Mallocs - memBefore.Mallocs
+			for i := range bySize {
+				bySize[i] += memAfter.BySize[i].Mallocs - memBefore.BySize[i].Mallocs
+			}
+		}
+
+		elapsed = time.Since(totalStart).Nanoseconds()
+		if br.TargetRSE > 0 || elapsed < br.minBenchmarkTimeNs {
+			iterations = min(br.nextPassIterations(iterations), br.maxIterations)
+		}
+	}
+
+	// Samples are nowNanos differences, so every one is monotonic
+	result.MonotonicTiming = true
+	if result.NegativeSamples > 0 {
+		fmt.Fprintf(os.Stderr, "warning: %s: %d negative durations measured and dropped, the clock is unreliable\n",
+			name, result.NegativeSamples)
+	}
+	warnWallClockStep(name, totalStart, time.Duration(elapsed))
+
+	result.Iterations = running.n
+	result.TotalTimeNs = float64(elapsed)
+	result.StopReason = StopTimeBudget
+	if ctx.Err() != nil {
+		result.StopReason = StopCanceled
+	} else if br.TargetRSE > 0 {
+		result.StopReason = StopMaxIterations
+		if rseReached {
+			result.StopReason = StopTargetRSE
+		}
+	} else if elapsed < br.minBenchmarkTimeNs {
+		result.StopReason = StopMaxIterations
+	} else if br.budgeted && running.n < br.minSamples {
+		result.StopReason = StopSuiteBudget
+	} else if extended {
+		result.StopReason = StopMinSamples
+	}
+	result.GCCount = gc.cycles() - gcBefore
+	if br.trackGCPauses {
+		var gcStatsAfter runtime.MemStats
+		runtime.ReadMemStats(&gcStatsAfter)
+		result.GCPauseTotalNs = gcStatsAfter.PauseTotalNs - gcStatsBefore.PauseTotalNs
+		result.GCPauseMaxNs = gcPauseMax(&gcStatsBefore, &gcStatsAfter)
+	}
+	if cpu := processCPUTime() - cpuBefore; cpu > 0 && elapsed > 0 {
+		result.CPUTimeNs = float64(cpu.Nanoseconds())
+		result.Parallelism = result.CPUTimeNs / float64(elapsed)
+	}
+	if br.trackAllocs && result.Iterations > 0 {
+		calls := float64(result.Iterations * batch)
+		result.AllocsMeasured = true
+		result.AllocBytesPerOp = float64(allocBytes) / calls
+		result.AllocsPerOp = float64(allocs) / calls
+		if br.allocSizes {
+			result.AllocSizes = allocSizeClasses(memAfter.BySize[:], bySize[:], allocs, calls)
+		}
+	}
+	if stream != nil {
+		result.Stats = stream.stats(running, br.confidenceLevel)
+		result.setTailThroughput()
+		return result, ctx.Err()
+	}
+	br.setStats(&result, measurements)
+	if gcSplit {
+		result.splitGCSamples(measurements, gcAffected, br.confidenceLevel)
+	}
+	return result, ctx.Err()
+}
+
+// warmUp calls fn before it is measured: for WarmupTime, or
+// warmupIterations times, or under adaptive warmup until its timings
+// settle. The timings are returned so the cold-start decay can be
+// inspected, except under a warmup time budget, which may take millions of
+// calls.
+func (br *BenchmarkRunner) warmUp(ctx context.Context, name string, fn func()) (warmup []float64, calls int) {
+	warmup = make([]float64, 0, br.warmupIterations)
+	warmupStart := time.Now()
+	for br.WarmupTimeNs > 0 && ctx.Err() == nil && time.Since(warmupStart).Nanoseconds() < br.WarmupTimeNs {
+		fn()
+		calls++
+	}
+	for br.WarmupTimeNs <= 0 && ctx.Err() == nil {
+		if len(warmup) >= br.warmupIterations {
+			// Adaptive warmup gives up on settling after a time budget's worth
+			// of calls, like a benchmark that never stops drifting would
+			if !br.adaptiveWarmup || warmupSettled(warmup) || len(warmup) >= maxAdaptiveWarmupIterations ||
+				time.Since(warmupStart).Nanoseconds() >= br.minBenchmarkTimeNs {
+				break
+			}
+		}
+		start := time.Now()
+		fn()
+		warmup = append(warmup, float64(time.Since(start).Nanoseconds()))
+		calls++
+	}
+	if br.debugWarmup {
+		for i, ns := range warmup {
+			fmt.Fprintf(os.Stderr, "warmup %-30s #%-4d %12s\n", name, i+1, formatDuration(ns))
+		}
+	}
+	return warmup, calls
+}
+
+// batchSize returns how many calls of fn one sample times
+func (br *BenchmarkRunner) batchSize(fn func()) int {
+	if br.autoBatch {
+		return calibrateBatchSize(fn)
+	}
+	return max(br.BatchSize, 1)
+}
+
+// setStats computes the statistics of result from its samples, trimming
+// outliers and keeping the samples if the runner is configured to
+func (br *BenchmarkRunner) setStats(result *BenchmarkResult, samples []float64) {
+	sorted := slices.Clone(samples)
+	sort.Float64s(sorted)
+	result.Stats.calculateSorted(sorted, br.confidenceLevel)
+	if br.trimOutliers {
+		result.Stats.TrimOutliers(sorted)
+	}
+	result.detectSingleSpike(sorted)
+	result.setTailThroughput()
+	if br.keepSamples {
+		result.Samples = samples
+	}
+}
+
+// abAllocCalls caps the untimed calls RunAB counts allocations over
+const abAllocCalls = 10000
+
+// countAllocs sets the allocation statistics of result from calls untimed
+// calls of fn
+func (br *BenchmarkRunner) countAllocs(result *BenchmarkResult, fn func(), calls int) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := 0; i < calls; i++ {
+		fn()
+	}
+	runtime.ReadMemStats(&after)
+
+	allocs := after.Mallocs - before.Mallocs
+	result.AllocsMeasured = true
+	result.AllocBytesPerOp = float64(after.TotalAlloc-before.TotalAlloc) / float64(calls)
+	result.AllocsPerOp = float64(allocs) / float64(calls)
+	if br.allocSizes {
+		bySize := make([]uint64, len(after.BySize))
+		for i := range bySize {
+			bySize[i] = after.BySize[i].Mallocs - before.BySize[i].Mallocs
+		}
+		result.AllocSizes = allocSizeClasses(after.BySize[:], bySize, allocs, float64(calls))
+	}
+}
+
+// splitGCSamples sets the GC-free and GC-affected statistics from the
+// samples and the ascending indexes of the GC-affected ones
+func (br *BenchmarkResult) splitGCSamples(samples []float64, affected []int, level float64) {
+	br.GCAffectedSamples = len(affected)
+	clean := make([]float64, 0, len(samples)-len(affected))
+	dirty := make([]float64, 0, len(affected))
+	for i, sample := range samples {
+		if len(affected) > 0 && affected[0] == i {
+			dirty = append(dirty, sample)
+			affected = affected[1:]
+		} else {
+			clean = append(clean, sample)
+		}
+	}
+	if len(clean) > 0 {
+		br.CleanStats = &BenchmarkStats{}
+		br.CleanStats.CalculateWithConfidence(clean, level)
+	}
+	if len(dirty) > 0 {
+		br.GCAffectedStats = &BenchmarkStats{}
+		br.GCAffectedStats.CalculateWithConfidence(dirty, level)
+	}
+}
+
+// startCPUProfile starts a pprof CPU profile written to path; stop ends it
+// and closes the file
+func startCPUProfile(path string) (stop func() error, err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating CPU profile: %w", err)
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("starting CPU profile: %w", err)
+	}
+	return func() error {
+		pprof.StopCPUProfile()
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("writing CPU profile: %w", err)
+		}
+		return nil
+	}, nil
+}
+
+// spikeMedianFactor is how many times the median a sample must take to count
+// as a spike
+const spikeMedianFactor = 100
+
+// spikeWarnShift is the mean shift, relative to the median, above which a
+// single spike is worth a warning; below it the spike is only recorded
+const spikeWarnShift = 0.05
+
+// detectSingleSpike flags a result whose max comes from one sample far
+// above all others. sorted must be the sorted samples behind br.Stats.
+// Several spikes point at a systematic cause instead and are left to the
+// usual statistics.
+func (br *BenchmarkResult) detectSingleSpike(sorted []float64) {
+	n := len(sorted)
+	if n < 2 || br.Stats.MedianNs <= 0 {
+		return
+	}
+	threshold := br.Stats.MedianNs * spikeMedianFactor
+	if sorted[n-1] <= threshold || sorted[n-2] > threshold {
+		return
+	}
+	br.SingleSpikeDetected = true
+	br.SpikeRatio = sorted[n-1] / br.Stats.MedianNs
+	br.SpikeMeanShiftNs = (sorted[n-1] - br.Stats.MedianNs) / float64(n)
+	if br.SpikeMeanShiftNs < br.Stats.MedianNs*spikeWarnShift {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "warning: %s: one sample was %.0fx the median and raised the mean by %s, consider re-running\n",
+		br.Name, br.SpikeRatio, formatDuration(br.SpikeMeanShiftNs))
+}
+
+// wallClockStepTolerance is how far the wall clock may drift from the
+// monotonic clock over one benchmark before warnWallClockStep complains
+const wallClockStepTolerance = 10 * time.Millisecond
+
+// warnWallClockStep warns when the wall clock moved differently from the
+// monotonic clock since start, i.e. it was stepped during the benchmark.
+// Results are unaffected since they use monotonic time, but a step is worth
+// knowing about when comparing timestamps of long runs.
+func warnWallClockStep(name string, start time.Time, monotonic time.Duration) {
+	wall := time.Now().Round(0).Sub(start.Round(0)) // Round(0) strips the monotonic reading
+	if step := wall - monotonic; step > wallClockStepTolerance || step < -wallClockStepTolerance {
+		fmt.Fprintf(os.Stderr, "warning: %s: wall clock stepped by %s during the run, timings use the monotonic clock\n",
+			name, step)
+	}
+}
+
+// ndjsonSink writes one JSON document per line, buffered so that emitting
+// a record doesn't cost a write syscall in the middle of a measurement
+type ndjsonSink struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func newNDJSONSink(w io.Writer) *ndjsonSink {
+	buffered := bufio.NewWriter(w)
+	return &ndjsonSink{w: buffered, enc: json.NewEncoder(buffered)}
+}
+
+func (s *ndjsonSink) write(record any) error {
+	return s.enc.Encode(record)
+}
+
+func (s *ndjsonSink) flush() error {
+	return s.w.Flush()
+}
+
+// latencySample is one streamed measurement. OffsetNs is the time since
+// the stream started, for plotting samples on a timeline.
+type latencySample struct {
+	Name      string `json:"name"`
+	Seq       int    `json:"seq"`
+	OffsetNs  int64  `json:"offset_ns"`
+	LatencyNs int64  `json:"latency_ns"`
+}
+
+// streamFlushInterval bounds how stale the streamed output can get
+const streamFlushInterval = 100 * time.Millisecond
+
+// latencyStream times every call of the benchmark called name and writes
+// each duration to sink until duration passes (zero runs until ctx ends)
+type latencyStream struct {
+	ctx      context.Context
+	name     string
+	duration time.Duration
+	sink     *ndjsonSink
+
+	matched bool
+	err     error
+}
+
+func (s *latencyStream) run(benchmarkFunc func()) {
+	s.matched = true
+	start := time.Now()
+	lastFlush := start
+	for seq := 0; s.ctx.Err() == nil; seq++ {
+		callStart := time.Now()
+		benchmarkFunc()
+		latency := time.Since(callStart)
+
+		offset := callStart.Sub(start)
+		if s.duration > 0 && offset >= s.duration {
+			break
+		}
+		if err := s.sink.write(latencySample{s.name, seq, offset.Nanoseconds(), latency.Nanoseconds()}); err != nil {
+			s.err = err
+			return
+		}
+		if now := time.Now(); now.Sub(lastFlush) >= streamFlushInterval {
+			if err := s.sink.flush(); err != nil {
+				s.err = err
+				return
+			}
+			lastFlush = now
+		}
+	}
+	s.err = s.sink.flush()
+}
+
+// benchmarkEntry is a registered benchmark. run may return several results
+// when the benchmark sweeps over parameters. tags name the areas it
+// covers, for -tags and the per-tag geometric means.
+type benchmarkEntry struct {
+	name string
+	kind BenchmarkKind
+	tags []string
+	run  func(runner *BenchmarkRunner) []BenchmarkResult
+}
+
+var benchmarkRegistry []benchmarkEntry
+
+// RegisterKind registers a single-closure benchmark along with its nature
+// and tags
+func RegisterKind(name string, kind BenchmarkKind, fn func(), tags ...string) {
+	registerSweep(name, kind, func(runner *BenchmarkRunner) []BenchmarkResult {
+		return []BenchmarkResult{runner.Run(name, fn)}
+	}, tags...)
+}
+
+// registerBenchmark registers a benchmark function that drives the runner itself
+func registerBenchmark(name string, kind BenchmarkKind, run func(runner *BenchmarkRunner) BenchmarkResult, tags ...string) {
+	registerSweep(name, kind, func(runner *BenchmarkRunner) []BenchmarkResult {
+		return []BenchmarkResult{run(runner)}
+	}, tags...)
+}
+
+// registerSweep registers a benchmark function producing several results
+func registerSweep(name string, kind BenchmarkKind, run func(runner *BenchmarkRunner) []BenchmarkResult, tags ...string) {
+	benchmarkRegistry = append(benchmarkRegistry, benchmarkEntry{name: name, kind: kind, tags: tags, run: run})
+}
+
+// registeredTags returns the sorted tags of the registered benchmarks
+func registeredTags() []string {
+	var tags []string
+	for _, entry := range benchmarkRegistry {
+		for _, tag := range entry.tags {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	slices.Sort(tags)
+	return tags
+}
+
+// suiteOptions carries command-line settings applied to every benchmark's runner
+type suiteOptions struct {
+	debugWarmup      bool
+	adaptiveWarmup   bool
+	warmupTime       time.Duration
+	trimOutliers     bool
+	benchmem         bool
+	gcPauses         bool
+	streamingStats   bool
+	keepSamples      bool
+	gcClean          bool
+	allocSizes       bool
+	autoBatch        bool
+	batchSize        int
+	targetRSE        float64
+	heapBallastBytes int
+
+	// minSamples replaces RunnerConfig.MinSamples (-min-samples)
+	minSamples int
+
+	// subtractTimerOverhead sets RunnerConfig.SubtractTimerOverhead
+	subtractTimerOverhead bool
+
+	// shuffleSeed, when set, randomizes the execution order so drift over
+	// the run (e.g. a warming CPU) isn't always charged to the same benchmarks
+	shuffleSeed *int64
+
+	// categories restricts the run to benchmarks of these kinds; empty runs all
+	categories []BenchmarkKind
+
+	// tags restricts the run to benchmarks with any of these tags; empty runs all
+	tags []string
+
+	// budget, when set, is the total time the measured loops may take,
+	// split evenly across the results. Results that stop early (e.g. at
+	// maxIterations) leave their share unused unless redistribute hands it
+	// to the results still to run.
+	budget       time.Duration
+	redistribute bool
+
+	// filter, like go test -run, restricts the run to results whose name
+	// matches it; a match on a registered benchmark's name keeps all of its
+	// results. nil runs everything.
+	filter *regexp.Regexp
+
+	// cpuProfile is the CPU profile path of the one selected result; see
+	// RunnerConfig.CPUProfile
+	cpuProfile string
+
+	// stream streams the samples of one benchmark instead of a normal run
+	stream *latencyStream
+
+	// onResult, when set, is called with every result as soon as its
+	// benchmark completes
+	onResult func(result BenchmarkResult)
+
+	// ctx cancels the run; benchmarks stop between samples once it is done
+	ctx context.Context
+}
+
+// newRunner creates the runner for one registered benchmark
+func (opts suiteOptions) newRunner(kind BenchmarkKind) *BenchmarkRunner {
+	runner := newBenchmarkRunnerForKind(kind)
+	runner.debugWarmup = opts.debugWarmup
+	runner.adaptiveWarmup = opts.adaptiveWarmup
+	runner.WarmupTimeNs = opts.warmupTime.Nanoseconds()
+	runner.autoBatch = opts.autoBatch
+	runner.BatchSize = max(opts.batchSize, 1)
+	runner.TargetRSE = opts.targetRSE
+	runner.trimOutliers = opts.trimOutliers
+	runner.minSamples = opts.minSamples
+	if opts.subtractTimerOverhead {
+		runner.timerOverhead = calibrateTimerOverhead()
+	}
+	if opts.benchmem {
+		runner.trackAllocs = true
+	}
+	runner.trackGCPauses = opts.gcPauses
+	runner.streamingStats = opts.streamingStats
+	runner.keepSamples = opts.keepSamples
+	runner.separateGCSamples = opts.gcClean
+	if opts.allocSizes {
+		runner.trackAllocs = true
+		runner.allocSizes = true
+	}
+	runner.HeapBallastBytes = opts.heapBallastBytes
+	runner.cpuProfile = opts.cpuProfile
+	runner.stream = opts.stream
+	runner.ctx = opts.ctx
+	return runner
+}
+
+// selectBenchmarks returns the registered benchmarks opts asks for
+func (opts suiteOptions) selectBenchmarks() []benchmarkEntry {
+	var entries []benchmarkEntry
+	for _, entry := range benchmarkRegistry {
+		if len(opts.categories) > 0 && !slices.Contains(opts.categories, entry.kind) {
+			continue
+		}
+		if len(opts.tags) > 0 && !slices.ContainsFunc(entry.tags, func(tag string) bool { return slices.Contains(opts.tags, tag) }) {
+			continue
+		}
+		if opts.filter != nil && !opts.filter.MatchString(entry.name) {
+			if runs, _ := opts.estimateBenchmark(entry); runs == 0 {
+				continue // nothing matches the filter
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// entryRunner creates the runner for entry, which skips the results the
+// filter doesn't select
+func (opts suiteOptions) entryRunner(entry benchmarkEntry) *BenchmarkRunner {
+	runner := opts.newRunner(entry.kind)
+	if opts.filter != nil && !opts.filter.MatchString(entry.name) {
+		runner.filter = opts.filter
+	}
+	return runner
+}
+
+// selected reports whether the filter selects result of entry
+func (opts suiteOptions) selected(entry benchmarkEntry, result BenchmarkResult) bool {
+	return opts.filter == nil || opts.filter.MatchString(entry.name) || opts.filter.MatchString(result.Name)
+}
+
+// estimatedRunOverhead is the allowance per result for work outside the time
+// budget: warmup, batch calibration and setup such as generating inputs
+const estimatedRunOverhead = 20 * time.Millisecond
+
+// estimateBenchmark dry-runs entry, which skips setup as well as the timed
+// calls, to count the results it produces and returns that count with the
+// time they are expected to take. Each result runs until its time budget at
+// most, so the estimate is an upper bound unless warmup or setup is
+// unusually slow.
+func (opts suiteOptions) estimateBenchmark(entry benchmarkEntry) (runs int, estimate time.Duration) {
+	runner := opts.entryRunner(entry)
+	runner.dryRun = true
+	for _, result := range runEntry(entry, runner) {
+		if result.Failed || opts.selected(entry, result) {
+			runs++
+		}
+	}
+	perRun := time.Duration(runner.minBenchmarkTimeNs) + estimatedRunOverhead
+	return runs, time.Duration(runs) * perRun
+}
+
+// printBenchmarkList lists the selected benchmarks sorted by name, so the
+// output can be diffed, with their kind, result count and estimated run
+// time. A benchmark with several results is the group their names share.
+func printBenchmarkList(opts suiteOptions) {
+	entries := opts.selectBenchmarks()
+	slices.SortStableFunc(entries, func(a, b benchmarkEntry) int { return strings.Compare(a.name, b.name) })
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.name
+	}
+	width := fittedNameWidth(names)
+	fmt.Printf("%-*s %-12s %8s %12s  %s\n", width, "Benchmark", "Category", "Results", "Est. Time", "Tags")
+	for _, entry := range entries {
+		runs, estimate := opts.estimateBenchmark(entry)
+		fmt.Printf("%-*s %-12s %8d %12s  %s\n", width, entry.name, entry.kind, runs, estimate.Round(100*time.Millisecond),
+			strings.Join(entry.tags, ","))
+	}
+}
+
+// selectedResultNames returns the sorted names of the results a run with
+// opts produces, found by dry-running every selected benchmark
+func (opts suiteOptions) selectedResultNames() []string {
+	var names []string
+	for _, entry := range opts.selectBenchmarks() {
+		runner := opts.entryRunner(entry)
+		runner.dryRun = true
+		for _, result := range runEntry(entry, runner) {
+			if opts.selected(entry, result) {
+				names = append(names, result.Name)
+			}
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// writeBenchmarkListJSON writes the result names of -list -json as a JSON
+// array, for tooling that feeds them back through -filter or -stream-latency
+func writeBenchmarkListJSON(w io.Writer, opts suiteOptions) error {
+	names := opts.selectedResultNames()
+	if names == nil {
+		names = []string{} // [] rather than null
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(names)
+}
+
+// selectedResults returns how many results a run with opts produces
+func (opts suiteOptions) selectedResults() int {
+	runs := 0
+	for _, entry := range opts.selectBenchmarks() {
+		n, _ := opts.estimateBenchmark(entry)
+		runs += n
+	}
+	return runs
+}
+
+// printSuiteEstimate prints the estimated run time of the selected
+// benchmarks without running any of them
+func printSuiteEstimate(opts suiteOptions) {
+	var benchmarks, runs int
+	var total time.Duration
+	for _, entry := range opts.selectBenchmarks() {
+		n, estimate := opts.estimateBenchmark(entry)
+		benchmarks++
+		runs += n
+		total += estimate
+	}
+	fmt.Printf("Estimated suite run time: %s (%d benchmarks, %d results, time budgets plus %s overhead per result)\n",
+		total.Round(time.Second), benchmarks, runs, estimatedRunOverhead)
+}
+
+// parseCategories parses a comma separated list of benchmark kinds
+func parseCategories(value string) ([]BenchmarkKind, error) {
+	var kinds []BenchmarkKind
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		var kind BenchmarkKind
+		if err := kind.UnmarshalText([]byte(name)); err != nil {
+			return nil, err
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
+
+// parseTags parses a comma separated list of benchmark tags, which must be
+// registered so a typo doesn't silently select nothing
+func parseTags(value string) ([]string, error) {
+	known := registeredTags()
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag == "" {
+			continue
+		}
+		if !slices.Contains(known, tag) {
+			return nil, fmt.Errorf("unknown tag %q (known: %s)", tag, strings.Join(known, ", "))
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// printCategories lists every benchmark category with its size and the
+// estimated time its benchmarks are budgeted to take
+func printCategories(opts suiteOptions) {
+	type category struct {
+		benchmarks, runs int
+		budget           time.Duration
+	}
+	categories := make(map[BenchmarkKind]*category)
+	for _, entry := range opts.selectBenchmarks() {
+		c := categories[entry.kind]
+		if c == nil {
+			c = &category{}
+			categories[entry.kind] = c
+		}
+		runs, budget := opts.estimateBenchmark(entry)
+		c.benchmarks++
+		c.runs += runs
+		c.budget += budget
+	}
+
+	kinds := make([]BenchmarkKind, 0, len(categories))
+	for kind := range categories {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i].String() < kinds[j].String() })
+
+	fmt.Printf("%-12s %10s %8s %12s\n", "Category", "Benchmarks", "Results", "Est. Time")
+	for _, kind := range kinds {
+		c := categories[kind]
+		fmt.Printf("%-12s %10d %8d %12s\n", kind, c.benchmarks, c.runs, c.budget.Round(100*time.Millisecond))
+	}
+}
+
+// runRegisteredBenchmarks runs the selected benchmarks in registration order,
+// each with a fresh runner configured for its kind. Once opts.ctx is
+// canceled it stops, keeping only results that collected samples.
+func runRegisteredBenchmarks(opts suiteOptions) []BenchmarkResult {
+	entries := opts.selectBenchmarks()
+	if opts.shuffleSeed != nil {
+		rng := rand.New(rand.NewSource(*opts.shuffleSeed))
+		rng.Shuffle(len(entries), func(i, j int) {
+			entries[i], entries[j] = entries[j], entries[i]
+		})
+	}
+
+	// With a suite budget every result gets an equal share of it as its
+	// time budget, instead of the per-kind default
+	var pending int
+	runs := make(map[string]int)
+	if opts.budget > 0 {
+		for _, entry := range entries {
+			runs[entry.name], _ = opts.estimateBenchmark(entry)
+			pending += runs[entry.name]
+		}
+		if pending > 0 {
+			note := ""
+			if opts.redistribute {
+				note = " (unused time is redistributed)"
+			}
+			fmt.Printf("Time budget: %s over %d results, %s each%s\n", opts.budget, pending,
+				(opts.budget / time.Duration(pending)).Round(time.Microsecond), note)
+		}
+	}
+	suiteStart := time.Now()
+
+	var results []BenchmarkResult
+	for _, entry := range entries {
+		runner := opts.entryRunner(entry)
+		if opts.budget > 0 && pending > 0 {
+			share := opts.budget / time.Duration(pending)
+			if opts.redistribute {
+				// What earlier benchmarks left over (or overran, setup
+				// included) is shared among the ones still to run
+				share = (opts.budget - time.Since(suiteStart)) / time.Duration(pending)
+			}
+			runner.minBenchmarkTimeNs = max(share.Nanoseconds(), 1)
+			runner.budgeted = true
+			pending -= runs[entry.name]
+		}
+		for _, result := range runEntry(entry, runner) {
+			if result.StopReason == StopCanceled && result.Iterations == 0 || !result.Failed && !opts.selected(entry, result) {
+				continue
+			}
+			results = append(results, result)
+			if opts.onResult != nil {
+				opts.onResult(result)
+			}
+		}
+		if opts.ctx != nil && opts.ctx.Err() != nil {
+			break
+		}
+	}
+	return results
+}
+
+// runEntry runs a registered benchmark. Panics inside Run are caught per
+// result; one in the benchmark's own code (e.g. its setup) fails the whole
+// entry, losing the results it had collected so far. The stack trace is
+// left to the real run, not the dry run that estimates it.
+func runEntry(entry benchmarkEntry, runner *BenchmarkRunner) (results []BenchmarkResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			if !runner.dryRun {
+				fmt.Fprintf(os.Stderr, "benchmark %s panicked: %v\n%s", entry.name, r, debug.Stack())
+			}
+			results = []BenchmarkResult{{Name: entry.name, Kind: entry.kind, Tags: entry.tags, Failed: true, Error: fmt.Sprint(r)}}
+		}
+	}()
+	results = entry.run(runner)
+	for i := range results {
+		results[i].Tags = entry.tags
+	}
+	return results
+}
+
+// Goroutine creation and execution benchmark
+func benchmarkGoroutineCreationAndExecution(runner *BenchmarkRunner) BenchmarkResult {
+	return runner.Run("Goroutine Creation & Execution", func() {
+		done := make(chan int)
+		go func() {
+			// 模拟协程执行中的一些计算
+			sum := 0
+			for i := 0; i < 10; i++ {
+				sum += i
+			}
+			done <- sum
+		}()
+		_ = <-done
+	})
+}
+
+// Channel operations benchmark
+func benchmarkChannelOps(runner *BenchmarkRunner) BenchmarkResult {
+	return runner.Run("Channel Operations", func() {
+		ch := make(chan int, 1)
+		ch <- 42
+		<-ch
+	})
+}
+
+// Simple computation benchmark
+func benchmarkSimpleComputation(runner *BenchmarkRunner) BenchmarkResult {
+	return runner.Run("Simple Computation", func() {
+		sum := 0
+		for i := 0; i < 100; i++ {
+			sum += i
+		}
+		_ = sum
+	})
+}
+
+// Complex computation benchmark - 测试调度器处理复杂计算的能力
+func benchmarkComplexComputation(runner *BenchmarkRunner) BenchmarkResult {
+	return runner.Run("Complex Computation Task", func() {
+		// 1. 矩阵运算 (3x3矩阵乘法)
+		matrixA := [9]float64{1.1, 2.2, 3.3, 4.4, 5.5, 6.6, 7.7, 8.8, 9.9}
+		matrixB := [9]float64{9.9, 8.8, 7.7, 6.6, 5.5, 4.4, 3.3, 2.2, 1.1}
+		var resultMatrix [9]float64
+
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				for k := 0; k < 3; k++ {
+					resultMatrix[i*3+j] += matrixA[i*3+k] * matrixB[k*3+j]
+				}
+			}
+		}
+
+		// 2. 字符串处理和哈希计算
+		data := "ComplexTaskBenchmark"
+		hash := uint64(0)
+		for _, c := range data {
+			hash = hash*31 + uint64(c)
+			hash ^= (hash >> 16)
+		}
+
+		// 3. 三角函数计算
+		trigSum := 0.0
+		for i := 1; i <= 50; i++ {
+			angle := float64(i) * 0.1
+			trigSum += math.Sin(angle)*math.Cos(angle) + math.Tan(angle*0.5)
+		}
+
+		// 4. 动态内存操作
+		dynamicData := make([]int, 100)
+		for i := 0; i < 100; i++ {
+			dynamicData[i] = i*i + int(hash%1000)
+		}
+
+		// 5. 复杂条件分支和数据处理
+		finalResult := 0.0
+		for i, val := range dynamicData {
+			if val%3 == 0 {
+				finalResult += math.Sqrt(float64(val))
+			} else if val%5 == 0 {
+				finalResult += math.Log(float64(val + 1))
+			} else {
+				finalResult += float64(val) * 0.1
+			}
+			_ = i // 防止编译器优化
+		}
+
+		// 6. 合并所有计算结果
+		total := 0.0
+		for _, val := range resultMatrix {
+			total += val
+		}
+		total += trigSum + finalResult + float64(hash)
+
+		_ = total // 防止编译器优化
+	})
+}
+
+// Data processing task benchmark (equivalent to FlowCoro)
+func benchmarkDataProcessingTask(runner *BenchmarkRunner) BenchmarkResult {
+	return runner.Run("Data Processing Task", func() {
+		data := make([]int, 50)
+		for i := range data {
+			data[i] = i * 2
+		}
+
+		sum := 0
+		for _, v := range data {
+			sum += v * v
+		}
+
+		result := float64(sum) / float64(len(data))
+		_ = result
+	})
+}
+
+// Request handler task benchmark (equivalent to FlowCoro)
+func benchmarkRequestHandlerTask(runner *BenchmarkRunner) BenchmarkResult {
+	return runner.Run("Request Handler Task", func() {
+		// Simulate request validation
+		valid := true
+		for i := 0; i < 20; i++ {
+			if i%7 == 0 {
+				valid = !valid
+			}
+		}
+
+		// Simulate data processing
+		if valid {
+			result := 0
+			for i := 0; i < 30; i++ {
+				result += i * i
+			}
+			_ = result
+		}
+	})
+}
+
+// Batch processing task benchmark (equivalent to FlowCoro)
+func benchmarkBatchProcessingTask(runner *BenchmarkRunner) BenchmarkResult {
+	return runner.RunN("Batch Processing Task", func() int {
+		batch := make([]int, 100)
+		for i := range batch {
+			batch[i] = i
+		}
+
+		// Process each item
+		results := make([]int, len(batch))
+		for i, item := range batch {
+			temp := item
+			for j := 0; j < 5; j++ {
+				temp = temp*2 + 1
+			}
+			results[i] = temp % 1000
+		}
+
+		// Calculate final result
+		sum := 0
+		for _, r := range results {
+			sum += r
+		}
+		_ = sum
+		return len(batch)
+	})
+}
+
+// Concurrent task processing benchmark (equivalent to FlowCoro)
+func benchmarkConcurrentTaskProcessing(runner *BenchmarkRunner) BenchmarkResult {
+	return runner.Run("Concurrent Task Processing", concurrentTask)
+}
+
+// concurrentTask fans a small computation out to 5 goroutines and combines
+// their results
+func concurrentTask() {
+	var wg sync.WaitGroup
+	results := make([]int, 5)
+
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+
+			// Each goroutine does some work
+			sum := 0
+			for j := 0; j < 50; j++ {
+				sum += (idx + 1) * j
+			}
+			results[idx] = sum
+		}(i)
+	}
+
+	wg.Wait()
+
+	// Combine results
+	total := 0
+	for _, r := range results {
+		total += r
+	}
+	_ = total
+}
+
+// Concurrent task scaling benchmark - 并发任务处理在不同并行度下的加速比与并行效率
+// Each worker runs whole concurrent tasks, so the speedup shows how well
+// independent tasks spread over the cores.
+func benchmarkConcurrentTaskScaling(runner *BenchmarkRunner) []BenchmarkResult {
+	return runner.RunScaling("Concurrent Task Scaling", []int{1, 2, 4, runtime.NumCPU()}, concurrentTask)
+}
+
+// Concurrent goroutines benchmark
+func benchmarkConcurrentGoroutines(runner *BenchmarkRunner) BenchmarkResult {
+	return runner.Run("Concurrent Goroutines (10)", func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				time.Sleep(1 * time.Microsecond)
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+// Real Echo server benchmark - fixed to test network IO performance only
+func benchmarkEchoServer(runner *BenchmarkRunner) BenchmarkResult {
+	return runner.Run("Echo Server Throughput", func() {
+		// Simulate network processing without server startup overhead
+		data := make([]byte, 20) // "Hello, Echo Server!\n"
+		for i := range data {
+			data[i] = byte(65 + (i % 26)) // Fill with letters
+		}
+
+		// Simulate echo processing
+		echo := make([]byte, len(data))
+		copy(echo, data)
+
+		// Simulate checksum validation
+		sum := 0
+		for _, b := range echo {
+			sum += int(b)
+		}
+		_ = sum
+	})
+}
+
+// Concurrent Echo clients benchmark - fixed
+func benchmarkConcurrentEchoClients(runner *BenchmarkRunner) BenchmarkResult {
+	return runner.Run("Concurrent Echo Clients", func() {
+		const clientCount = 100 // 与FlowCoro保持一致：100个并发任务
+		var wg sync.WaitGroup
+		wg.Add(clientCount)
+
+		for i := 0; i < clientCount; i++ {
+			go func() {
+				defer wg.Done()
+
+				// 模拟更多的网络处理工作（与FlowCoro一致）
+				work := 0
+				for j := 0; j < 1000; j++ { // 1000次循环，与FlowCoro一致
+					work += j * j // 更复杂的计算
+				}
+
+				// 模拟网络延迟（与FlowCoro的sleep_for对应）
+				time.Sleep(time.Microsecond)
+
+				_ = work // 防止编译器优化
+			}()
+		}
+
+		wg.Wait()
+	})
+}
+
+// dataTransferGroup groups the data transfer sizes in the summary
+const dataTransferGroup = "Data Transfer"
+
+// dataTransferSizes are the payload sizes of the data transfer sweep
+var dataTransferSizes = []int{64, 4096, 65536}
+
+var dataTransferSink int
+
+// Data transfer benchmarks - 不同负载大小下的缓冲区填充与校验
+func benchmarkDataTransfer(runner *BenchmarkRunner) []BenchmarkResult {
+	results := runner.RunSweep(dataTransferGroup, dataTransferSizes, func(size int) {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(i % 256)
+		}
+		// Simulate checksum
+		sum := 0
+		for _, b := range data {
+			sum += int(b)
+		}
+		dataTransferSink += sum
+	})
+	// Every call fills and checksums one payload
+	for i := range results {
+		results[i].BytesPerOp = results[i].Size
+	}
+	return results
+}
+
+// Memory allocation benchmark
+func benchmarkMemoryAllocation(runner *BenchmarkRunner) BenchmarkResult {
+	return runner.Run("Memory Allocation (1KB)", func() {
+		data := make([]byte, 1024)
+		// Use the data to prevent optimization
+		data[0] = 1
+		data[1023] = 1
+		_ = data
+	})
+}
+
+// allocationSink keeps the allocation rate benchmark's slices escaping to the heap
+var allocationSink []byte
+
+// allocationRate is what the allocation rate benchmark's windows counted
+type allocationRate struct {
+	mallocs, bytes uint64
+	windowNs       int64
+}
+
+// benchmarkAllocationRate measures allocator throughput at each of sizes:
+// every call is a window of count allocations of size bytes, preceded by a
+// forced GC so each window starts from the same heap. Unlike the
+// single-shot Memory Allocation (1KB) it reports the rates the runtime
+// counted, from MemStats, as the allocs/s and bytes/s metrics, over the
+// timed windows only.
+func benchmarkAllocationRate(runner *BenchmarkRunner, sizes []int, count int) []BenchmarkResult {
+	rates := make(map[int]*allocationRate) // by size, of the sizes run
+	results := runner.runTimedSweep(fmt.Sprintf("Allocation Rate (x%d)", count), sizes, func(size int) (func(*Timer), func()) {
+		rate := new(allocationRate)
+		rates[size] = rate
+		var before, after runtime.MemStats
+		return func(t *Timer) {
+			t.Stop()
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+			t.Start()
+			start := nowNanos()
+			for i := 0; i < count; i++ {
+				allocationSink = make([]byte, size)
+			}
+			rate.windowNs += nowNanos() - start
+			t.Stop()
+			runtime.ReadMemStats(&after)
+			t.Start()
+			rate.mallocs += after.Mallocs - before.Mallocs
+			rate.bytes += after.TotalAlloc - before.TotalAlloc
+		}, func() { allocationSink = nil }
+	})
+
+	for i := range results {
+		rate, ok := rates[results[i].Size]
+		if !ok || rate.windowNs <= 0 {
+			continue
+		}
+		seconds := float64(rate.windowNs) / 1e9
+		results[i].SetMetric("allocs/s", float64(rate.mallocs)/seconds)
+		results[i].SetMetric("bytes/s", float64(rate.bytes)/seconds)
+	}
+	normalizeResults(results, 0)
+	return results
+}
+
+// allocationRateSizes straddle the runtime's 32KB small object limit, above
+// which allocations skip the per-P caches and go to the heap directly
+var allocationRateSizes = []int{16, 256, 4096, 32768, 32769, 65536}
+
+// allocationRateCount is the number of allocations in one sampling window
+const allocationRateCount = 100
+
+// Allocation rate sweep - 分配速率随对象大小的变化，观察分配器快速路径的边界
+func benchmarkAllocationRateSweep(runner *BenchmarkRunner) []BenchmarkResult {
+	return benchmarkAllocationRate(runner, allocationRateSizes, allocationRateCount)
+}
+
+// HTTP request processing simulation
+func benchmarkHTTPProcessing(runner *BenchmarkRunner) BenchmarkResult {
+	return runner.Run("HTTP Request Processing", func() {
+		request := "GET /api/data HTTP/1.1\r\nHost: localhost\r\n\r\n"
+		response := "HTTP/1.1 200 OK\r\nContent-Length: 13\r\n\r\nHello, World!"
+
+		// Simulate request parsing
+		_ = len(request)
+		// Simulate response generation
+		_ = len(response)
+	})
+}
+
+// workerPool is a fixed set of goroutines draining a shared task channel.
+// Each submitted task runs work once and then signals the caller's WaitGroup,
+// so no closure is allocated per task.
+type workerPool struct {
+	tasks   chan *sync.WaitGroup
+	workers sync.WaitGroup
+}
+
+func newWorkerPool(size int, work func()) *workerPool {
+	p := &workerPool{tasks: make(chan *sync.WaitGroup, size)}
+	p.workers.Add(size)
+	for i := 0; i < size; i++ {
+		go func() {
+			defer p.workers.Done()
+			for done := range p.tasks {
+				work()
+				done.Done()
+			}
+		}()
+	}
+	return p
+}
+
+func (p *workerPool) submit(done *sync.WaitGroup) {
+	p.tasks <- done
+}
+
+func (p *workerPool) close() {
+	close(p.tasks)
+	p.workers.Wait()
+}
+
+// poolTaskSink keeps the pool benchmark's task results observable
+var poolTaskSink atomic.Int64
+
+func poolTask() {
+	sum := 0
+	for i := 0; i < 50; i++ {
+		sum += i * i
+	}
+	poolTaskSink.Add(int64(sum))
+}
+
+// Goroutine pool reuse vs fresh spawn benchmark - 每个任务新建goroutine vs 固定worker池复用
+// Allocations are tracked because pools mainly win on goroutine/stack reuse.
+// Each way sweeps the task count; a pool size is a way of its own.
+func benchmarkGoroutinePoolVsSpawn(runner *BenchmarkRunner) []BenchmarkResult {
+	runner.trackAllocs = true
+	taskCounts := []int{100, 1000}
+
+	results := runner.RunSweep("Goroutine Spawn", taskCounts, func(taskCount int) {
+		var wg sync.WaitGroup
+		wg.Add(taskCount)
+		for i := 0; i < taskCount; i++ {
+			go func() {
+				defer wg.Done()
+				poolTask()
+			}()
+		}
+		wg.Wait()
+	})
+	for _, poolSize := range []int{runtime.NumCPU(), 4 * runtime.NumCPU()} {
+		results = append(results, runner.RunSweepWithSetup(fmt.Sprintf("Worker Pool (%dw)", poolSize), taskCounts, func(taskCount int) (func(), func()) {
+			pool := newWorkerPool(poolSize, poolTask)
+			return func() {
+				var wg sync.WaitGroup
+				wg.Add(taskCount)
+				for i := 0; i < taskCount; i++ {
+					pool.submit(&wg)
+				}
+				wg.Wait()
+			}, pool.close
+		})...)
+	}
+	return results
+}
+
+// Spawn vs pool, interleaved - 交替运行两种实现，抵消温度与后台负载随时间的漂移
+func benchmarkSpawnVsPoolAB(runner *BenchmarkRunner) []BenchmarkResult {
+	const taskCount = 100
+	const name = "Spawn vs Pool (100 tasks)"
+	if runner.skipsAll(name+"/A", name+"/B") {
+		spawn, pooled := runner.RunAB(name, nil, nil)
+		return []BenchmarkResult{spawn, pooled}
+	}
+	pool := newWorkerPool(runtime.NumCPU(), poolTask)
+	defer pool.close()
+
+	spawn, pooled := runner.RunAB(name, func() {
+		var wg sync.WaitGroup
+		wg.Add(taskCount)
+		for i := 0; i < taskCount; i++ {
+			go func() {
+				defer wg.Done()
+				poolTask()
+			}()
+		}
+		wg.Wait()
+	}, func() {
+		var wg sync.WaitGroup
+		wg.Add(taskCount)
+		for i := 0; i < taskCount; i++ {
+			pool.submit(&wg)
+		}
+		wg.Wait()
+	})
+	return []BenchmarkResult{spawn, pooled}
+}
+
+// channelDrainSink receives the drained item sums
+var channelDrainSink atomic.Int64
+
+// reportItemThroughput records items moved per second for a benchmark whose
+// op moves items items
+func reportItemThroughput(result *BenchmarkResult, items int) {
+	if result.Stats.MeanNs > 0 {
+		result.SetMetric("items/s", float64(items)*1e9/result.Stats.MeanNs)
+	}
+}
+
+// Channel close/drain benchmark - 生产者关闭channel、消费者range排空的完整生命周期
+// "Close+Range" is the idiomatic shutdown; "Counted Recv" receives a known
+// item count without closing. Both sweep the item count per buffer size,
+// and the x baseline metric shows what close costs at each.
+func benchmarkChannelCloseDrain(runner *BenchmarkRunner) []BenchmarkResult {
+	itemCounts := []int{100, 10000}
+
+	var results []BenchmarkResult
+	for _, buffer := range []int{0, 64} {
+		counted := runner.RunSweep(fmt.Sprintf("Counted Recv (buf %d)", buffer), itemCounts, func(items int) {
+			ch := make(chan int, buffer)
+			go func() {
+				for i := 0; i < items; i++ {
+					ch <- i
+				}
+			}()
+			sum := 0
+			for i := 0; i < items; i++ {
+				sum += <-ch
+			}
+			channelDrainSink.Add(int64(sum))
+		})
+		closed := runner.RunSweep(fmt.Sprintf("Close+Range (buf %d)", buffer), itemCounts, func(items int) {
+			ch := make(chan int, buffer)
+			go func() {
+				for i := 0; i < items; i++ {
+					ch <- i
+				}
+				close(ch)
+			}()
+			sum := 0
+			for v := range ch {
+				sum += v
+			}
+			channelDrainSink.Add(int64(sum))
+		})
+
+		sweeps := [][]BenchmarkResult{counted, closed}
+		normalizeSweeps(sweeps, 0)
+		for _, sweep := range sweeps {
+			for i := range sweep {
+				reportItemThroughput(&sweep[i], sweep[i].Size)
+			}
+			results = append(results, sweep...)
+		}
+	}
+	return results
+}
+
+// poolGetsPerWorker is how many Get/Put pairs each goroutine does per op in
+// the sync.Pool benchmark
+const poolGetsPerWorker = 100
+
+// pooledBuffer is the object recycled through sync.Pool
+type pooledBuffer struct {
+	data [256]byte
+}
+
+// sync.Pool hit rate benchmark - 不同并发度下sync.Pool的命中率与分配
+// The sweep is over the goroutine count. Long-lived workers are released
+// together for every op so goroutine creation doesn't pollute allocs/op.
+// New is counted, so every miss is known exactly: a hit rate that drops as
+// goroutines are added means the per-P caches are thrashing.
+func benchmarkSyncPoolConcurrency(runner *BenchmarkRunner) []BenchmarkResult {
+	runner.trackAllocs = true
+	levels := []int{1, 4, runtime.NumCPU(), 4 * runtime.NumCPU()}
+	slices.Sort(levels)
+	levels = slices.Compact(levels) // small machines repeat levels
+
+	misses := make(map[int]*atomic.Int64) // by goroutine count, of the levels run
+	results := runner.RunSweepWithSetup("sync.Pool", levels, func(workers int) (func(), func()) {
+		levelMisses := new(atomic.Int64)
+		misses[workers] = levelMisses
+		pool := sync.Pool{New: func() any {
+			levelMisses.Add(1)
+			return new(pooledBuffer)
+		}}
+		p := newParallelWorkers(workers, func(int) {
+			for i := 0; i < poolGetsPerWorker; i++ {
+				buf := pool.Get().(*pooledBuffer)
+				buf.data[i]++
+				pool.Put(buf)
+			}
+		})
+		return p.run, p.close
+	})
+
+	for i := range results {
+		result := &results[i]
+		workers := result.Size
+		result.Workers = workers
+		levelMisses, ok := misses[workers]
+		if !ok {
+			continue
+		}
+		// Warmup and timed calls both count; the split doesn't matter for a rate
+		gets := float64((result.WarmupIterations + result.Iterations*max(result.BatchSize, 1)) * workers * poolGetsPerWorker)
+		if gets > 0 { // no calls when canceled
+			result.SetMetric("hit %", 100*(1-float64(levelMisses.Load())/gets))
+		}
+		if result.AllocsMeasured {
+			result.SetMetric("allocs/get", result.AllocsPerOp/float64(workers*poolGetsPerWorker))
+		}
+	}
+	return results
+}
+
+// broadcastGroup is a set of goroutines parked on a sync.Cond, woken
+// together by wake. It records when the last of them resumed.
+type broadcastGroup struct {
+	mu        sync.Mutex
+	cond      *sync.Cond // waiters park here until generation changes
+	allParked *sync.Cond // signalled when every waiter is parked
+	waiters   int
+	parked    int
+	gen       int
+	stop      bool
+
+	remaining atomic.Int64
+	lastWake  time.Time
+	woken     chan struct{}
+}
+
+func newBroadcastGroup(waiters int) *broadcastGroup {
+	g := &broadcastGroup{waiters: waiters, woken: make(chan struct{})}
+	g.cond = sync.NewCond(&g.mu)
+	g.allParked = sync.NewCond(&g.mu)
+	for i := 0; i < waiters; i++ {
+		go g.wait()
+	}
+	return g
+}
+
+func (g *broadcastGroup) wait() {
+	seen := 0
+	for {
+		g.mu.Lock()
+		g.parked++
+		if g.parked == g.waiters {
+			g.allParked.Signal()
+		}
+		for g.gen == seen {
+			g.cond.Wait()
+		}
+		seen = g.gen
+		stop := g.stop
+		g.mu.Unlock()
+		if stop {
+			return
+		}
+		if g.remaining.Add(-1) == 0 {
+			g.lastWake = time.Now()
+			g.woken <- struct{}{}
+		}
+	}
+}
+
+// wake broadcasts once every waiter is parked and returns how long the
+// last waiter took to resume
+func (g *broadcastGroup) wake() time.Duration {
+	g.mu.Lock()
+	for g.parked < g.waiters {
+		g.allParked.Wait()
+	}
+	g.parked = 0
+	g.remaining.Store(int64(g.waiters))
+	g.gen++
+	start := time.Now()
+	g.cond.Broadcast()
+	g.mu.Unlock()
+
+	<-g.woken
+	return g.lastWake.Sub(start)
+}
+
+func (g *broadcastGroup) close() {
+	g.mu.Lock()
+	g.stop = true
+	g.gen++
+	g.cond.Broadcast()
+	g.mu.Unlock()
+}
+
+// sync.Cond broadcast fan-out benchmark - Broadcast唤醒N个等待goroutine的延迟
+// The sweep is over the waiter count. The op time includes waiting for
+// every waiter to park again; the wake metrics are the latency from
+// Broadcast until the last waiter resumed.
+func benchmarkCondBroadcast(runner *BenchmarkRunner) []BenchmarkResult {
+	// Wake latencies go into room reserved up front, so appending never grows
+	// the slice inside a timed call. Calls past it, e.g. under a warmup time
+	// budget, aren't recorded.
+	capacity := runner.maxIterations + runner.warmupIterations
+	if runner.adaptiveWarmup {
+		capacity += maxAdaptiveWarmupIterations
+	}
+	wakes := make(map[int]*[]float64) // by waiter count, of the counts run
+	results := runner.RunSweepWithSetup("Cond Broadcast", []int{1, 10, 100, 1000}, func(waiters int) (func(), func()) {
+		group := newBroadcastGroup(waiters)
+		wakeNs := make([]float64, 0, capacity)
+		wakes[waiters] = &wakeNs
+		return func() {
+			wake := group.wake()
+			if len(wakeNs) < cap(wakeNs) {
+				wakeNs = append(wakeNs, float64(wake.Nanoseconds()))
+			}
+		}, group.close
+	})
+
+	for i := range results {
+		result := &results[i]
+		wakeNs, ok := wakes[result.Size]
+		if !ok || len(*wakeNs) <= result.WarmupIterations {
+			continue
+		}
+		var wake BenchmarkStats
+		wake.Calculate((*wakeNs)[result.WarmupIterations:])
+		result.SetMetric("last wake ns", wake.MeanNs)
+		result.SetMetric("last wake p50", wake.MedianNs)
+		result.SetMetric("last wake p95", wake.P95Ns)
+		result.SetMetric("last wake p99", wake.P99Ns)
+	}
+	return results
+}
+
+// concurrentCounter is implemented by each counter under comparison; worker
+// identifies the calling goroutine for implementations that shard by it
+type concurrentCounter interface {
+	inc(worker int)
+	value() int64
+}
+
+type mutexCounter struct {
+	mu sync.Mutex
+	n  int64
+}
+
+func (c *mutexCounter) inc(int) {
+	c.mu.Lock()
+	c.n++
+	c.mu.Unlock()
+}
+
+func (c *mutexCounter) value() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.n
+}
+
+type atomicCounter struct {
+	n atomic.Int64
+}
+
+func (c *atomicCounter) inc(int)      { c.n.Add(1) }
+func (c *atomicCounter) value() int64 { return c.n.Load() }
+
+// paddedInt64 fills a cache line so neighbouring shards don't false-share
+type paddedInt64 struct {
+	n atomic.Int64
+	_ [56]byte
+}
+
+// shardedCounter gives every worker its own cache line; reads sum them
+type shardedCounter struct {
+	shards []paddedInt64
+}
+
+func (c *shardedCounter) inc(worker int) { c.shards[worker%len(c.shards)].n.Add(1) }
+
+func (c *shardedCounter) value() int64 {
+	var total int64
+	for i := range c.shards {
+		total += c.shards[i].n.Load()
+	}
+	return total
+}
+
+// syncMapCounter keeps one counter per worker in a sync.Map, the approach
+// people reach for when the set of writers isn't known up front
+type syncMapCounter struct {
+	m sync.Map
+}
+
+func (c *syncMapCounter) inc(worker int) {
+	v, ok := c.m.Load(worker)
+	if !ok {
+		v, _ = c.m.LoadOrStore(worker, new(atomic.Int64))
+	}
+	v.(*atomic.Int64).Add(1)
+}
+
+func (c *syncMapCounter) value() int64 {
+	var total int64
+	c.m.Range(func(_, v any) bool {
+		total += v.(*atomic.Int64).Load()
+		return true
+	})
+	return total
+}
+
+// parallelWorkers keeps goroutines parked between ops and releases all of
+// them for each one, so starting goroutines isn't part of the measurement.
+// Every worker has its own release channel: with a shared one, a worker done
+// early could take a second release and do another's share of the op.
+type parallelWorkers struct {
+	wg    sync.WaitGroup
+	start []chan struct{}
+}
+
+// newParallelWorkers returns once every worker goroutine is running, so
+// their ramp-up doesn't land in the first sample
+func newParallelWorkers(count int, work func(worker int)) *parallelWorkers {
+	p := &parallelWorkers{start: make([]chan struct{}, count)}
+	var ready sync.WaitGroup
+	ready.Add(count)
+	for w := range p.start {
+		p.start[w] = make(chan struct{})
+		go func() {
+			ready.Done()
+			for range p.start[w] {
+				work(w)
+				p.wg.Done()
+			}
+		}()
+	}
+	ready.Wait()
+	return p
+}
+
+// run releases every worker once and waits for all of them to finish
+func (p *parallelWorkers) run() {
+	p.wg.Add(len(p.start))
+	for _, start := range p.start {
+		start <- struct{}{}
+	}
+	p.wg.Wait()
+}
+
+func (p *parallelWorkers) close() {
+	for _, start := range p.start {
+		close(start)
+	}
+}
+
+// Concurrent counter benchmark - 高并发写入下几种计数器实现的吞吐与扩展性
+// Every counter goes through RunScaling, so each call is one increment and
+// the efficiency metric shows how contention pulls scaling down: 1.0 is
+// perfect scaling.
+func benchmarkConcurrentCounters(runner *BenchmarkRunner) []BenchmarkResult {
+	levels := []int{1, 2, 4, 8, runtime.NumCPU()}
+	maxWorkers := slices.Max(levels)
+
+	counters := []struct {
+		name string
+		new  func(workers int) concurrentCounter
+	}{
+		{"Mutex Counter", func(int) concurrentCounter { return &mutexCounter{} }},
+		{"Atomic Counter", func(int) concurrentCounter { return &atomicCounter{} }},
+		{"Sharded Counter", func(workers int) concurrentCounter {
+			return &shardedCounter{shards: make([]paddedInt64, workers)}
+		}},
+		{"sync.Map Counter", func(int) concurrentCounter { return &syncMapCounter{} }},
+	}
+
+	var results []BenchmarkResult
+	for _, c := range counters {
+		// One counter serves every level, as the levels run one after another;
+		// calls tallies each worker's increments to check it against
+		counter := c.new(maxWorkers)
+		calls := make([]paddedInt64, maxWorkers)
+		scaling := runner.runScaling(c.name, levels, func(worker int) {
+			for i := 0; i < parallelCallsPerWorker; i++ {
+				counter.inc(worker)
+			}
+			calls[worker].n.Add(parallelCallsPerWorker)
+		})
+
+		var want int64
+		for i := range calls {
+			want += calls[i].n.Load()
+		}
+		if counter.value() != want {
+			fmt.Fprintf(os.Stderr, "warning: %s counted %d, want %d\n", c.name, counter.value(), want)
+		}
+		results = append(results, scaling...)
+	}
+	return results
+}
+
+// Parallel map read benchmark - 读多写少场景下RWMutex保护的map与sync.Map的并发读取
+// Every GOMAXPROCS worker looks up keys of a prefilled map; with one CPU
+// this shows the locking overhead, with more it shows reader contention on
+// the RWMutex's reader count.
+func benchmarkParallelMapReads(runner *BenchmarkRunner) []BenchmarkResult {
+	const keys = 1024
+	guarded := struct {
+		sync.RWMutex
+		m map[int]int
+	}{m: make(map[int]int, keys)}
+	var synced sync.Map
+	for k := 0; k < keys; k++ {
+		guarded.m[k] = k
+		synced.Store(k, k)
+	}
+
+	// The global math/rand functions draw from per-thread state, so picking
+	// keys doesn't add a shared cache line of its own
+	return []BenchmarkResult{
+		runner.RunParallel("RWMutex Map Read (parallel)", 0, func() {
+			k := rand.Intn(keys)
+			guarded.RLock()
+			v := guarded.m[k]
+			guarded.RUnlock()
+			if v != k {
+				panic("RWMutex map lookup mismatch")
+			}
+		}),
+		runner.RunParallel("sync.Map Read (parallel)", 0, func() {
+			k := rand.Intn(keys)
+			if v, _ := synced.Load(k); v.(int) != k {
+				panic("sync.Map lookup mismatch")
+			}
+		}),
+	}
+}
+
+// tokenBucket is a classic mutex-guarded token bucket, the same model as
+// golang.org/x/time/rate. Time is passed in so benchmarks control refill.
+type tokenBucket struct {
+	mu     sync.Mutex
+	tokens float64
+	burst  float64
+	perNs  float64 // tokens refilled per nanosecond
+	lastNs int64
+}
+
+func newTokenBucket(ratePerSec float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		tokens: float64(burst),
+		burst:  float64(burst),
+		perNs:  ratePerSec / 1e9,
+	}
+}
+
+func (b *tokenBucket) allowAt(nowNs int64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = math.Min(b.burst, b.tokens+float64(nowNs-b.lastNs)*b.perNs)
+	b.lastNs = nowNs
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// gcraLimiter is a lock-free limiter based on the generic cell rate algorithm:
+// a single atomic "theoretical arrival time" replaces the token count.
+type gcraLimiter struct {
+	tat         atomic.Int64
+	intervalNs  int64
+	toleranceNs int64
+}
+
+func newGCRALimiter(ratePerSec float64, burst int) *gcraLimiter {
+	interval := int64(1e9 / ratePerSec)
+	return &gcraLimiter{
+		intervalNs:  interval,
+		toleranceNs: int64(burst-1) * interval,
+	}
+}
+
+func (l *gcraLimiter) allowAt(nowNs int64) bool {
+	for {
+		tat := l.tat.Load()
+		if nowNs < tat-l.toleranceNs {
+			return false
+		}
+		if l.tat.CompareAndSwap(tat, max(tat, nowNs)+l.intervalNs) {
+			return true
+		}
+	}
+}
+
+// Rate limiter fast-path benchmark - 令牌充足时限流中间件的单请求开销
+// The simulated clock advances by exactly one token interval per request, so
+// every call finds a token available; clock reads are deliberately excluded.
+func benchmarkRateLimiter(runner *BenchmarkRunner) []BenchmarkResult {
+	runner.trackAllocs = true
+
+	var results []BenchmarkResult
+	for _, rate := range []float64{1_000, 1_000_000} {
+		intervalNs := int64(1e9 / rate)
+
+		bucket := newTokenBucket(rate, 10)
+		clock, rejected := int64(0), 0
+		results = append(results, runner.Run(fmt.Sprintf("Token Bucket (%.0f/s)", rate), func() {
+			clock += intervalNs
+			if !bucket.allowAt(clock) {
+				rejected++
+			}
+		}))
+		reportRateLimiterSlowPath(results[len(results)-1], rejected)
+
+		gcra := newGCRALimiter(rate, 10)
+		clock, rejected = 0, 0
+		results = append(results, runner.Run(fmt.Sprintf("GCRA Atomic (%.0f/s)", rate), func() {
+			clock += intervalNs
+			if !gcra.allowAt(clock) {
+				rejected++
+			}
+		}))
+		reportRateLimiterSlowPath(results[len(results)-1], rejected)
+	}
+	return results
+}
+
+// reportRateLimiterSlowPath warns when a limiter benchmark left the fast path,
+// which would mean the numbers include rejected requests.
+func reportRateLimiterSlowPath(result BenchmarkResult, rejected int) {
+	if rejected > 0 {
+		fmt.Fprintf(os.Stderr, "warning: %s rejected %d requests\n", result.Name, rejected)
+	}
+}
+
+// benchmarkSeed seeds every generated benchmark input so runs are reproducible
+var benchmarkSeed int64 = 1
+
+// newBenchmarkRand returns a generator for benchmark inputs seeded with benchmarkSeed
+func newBenchmarkRand() *rand.Rand {
+	return rand.New(rand.NewSource(benchmarkSeed))
+}
+
+// randomInts returns n pseudo-random ints from the seeded generator
+func randomInts(n int) []int {
+	rng := newBenchmarkRand()
+	values := make([]int, n)
+	for i := range values {
+		values[i] = rng.Intn(1 << 30)
+	}
+	return values
+}
+
+// slicesSink keeps slices benchmark results observable
+var slicesSink int
+
+// slices package benchmark - 标准库slices操作与手写实现对比
+// Each operation sweeps the slice length over the same seeded input.
+func benchmarkSlicesPackage(runner *BenchmarkRunner) []BenchmarkResult {
+	sizes := []int{16, 256, 4096}
+	const missing = -1 // never generated, so searches scan the whole slice
+
+	results := runner.RunSweepWithSetup("slices.Sort", sizes, func(size int) (func(), func()) {
+		input, work := randomInts(size), make([]int, size)
+		return func() {
+			copy(work, input)
+			slices.Sort(work)
+			slicesSink += work[0]
+		}, nil
+	})
+	results = append(results, runner.RunSweepWithSetup("sort.Ints", sizes, func(size int) (func(), func()) {
+		input, work := randomInts(size), make([]int, size)
+		return func() {
+			copy(work, input)
+			sort.Ints(work)
+			slicesSink += work[0]
+		}, nil
+	})...)
+
+	results = append(results, runner.RunSweepWithSetup("slices.Contains", sizes, func(size int) (func(), func()) {
+		input := randomInts(size)
+		return func() {
+			if slices.Contains(input, missing) {
+				slicesSink++
+			}
+		}, nil
+	})...)
+	results = append(results, runner.RunSweepWithSetup("Manual Contains", sizes, func(size int) (func(), func()) {
+		input := randomInts(size)
+		return func() {
+			for _, v := range input {
+				if v == missing {
+					slicesSink++
+					break
+				}
+			}
+		}, nil
+	})...)
+
+	results = append(results, runner.RunSweepWithSetup("slices.Index", sizes, func(size int) (func(), func()) {
+		input := randomInts(size)
+		target := input[size-1]
+		return func() {
+			slicesSink += slices.Index(input, target)
+		}, nil
+	})...)
+	results = append(results, runner.RunSweepWithSetup("Manual Index", sizes, func(size int) (func(), func()) {
+		input := randomInts(size)
+		target := input[size-1]
+		return func() {
+			for i, v := range input {
+				if v == target {
+					slicesSink += i
+					break
+				}
+			}
+		}, nil
+	})...)
+
+	// Clone allocations are part of the comparison
+	trackAllocs := runner.trackAllocs
+	runner.trackAllocs = true
+	results = append(results, runner.RunSweepWithSetup("slices.Clone", sizes, func(size int) (func(), func()) {
+		input := randomInts(size)
+		return func() {
+			clone := slices.Clone(input)
+			slicesSink += clone[size-1]
+		}, nil
+	})...)
+	results = append(results, runner.RunSweepWithSetup("Manual Clone", sizes, func(size int) (func(), func()) {
+		input := randomInts(size)
+		return func() {
+			clone := make([]int, len(input))
+			copy(clone, input)
+			slicesSink += clone[size-1]
+		}, nil
+	})...)
+	runner.trackAllocs = trackAllocs
+	return results
+}
+
+// floatSink keeps the numeric loop accumulators observable
+var floatSink float64
+
+// randomFloats returns n seeded float64 values in [0, 1)
+func randomFloats(n int) []float64 {
+	rng := newBenchmarkRand()
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = rng.Float64()
+	}
+	return values
+}
+
+// reportFlops records GFLOP/s and ns per element for a result whose op does
+// flopsPerElem floating point operations on each of elems elements
+func reportFlops(result *BenchmarkResult, elems, flopsPerElem int) {
+	if result.Stats.MeanNs <= 0 {
+		return
+	}
+	result.SetMetric("GFLOP/s", float64(elems*flopsPerElem)/result.Stats.MeanNs)
+	result.SetMetric("ns/elem", result.Stats.MeanNs/float64(elems))
+}
+
+// Numeric loop benchmark - 浮点切片紧循环吞吐，按大小扫过L1/L2/L3/内存
+// Sizes are chosen so the working set moves out of each cache level in turn;
+// ns/elem makes them directly comparable.
+func benchmarkFloatLoops(runner *BenchmarkRunner) []BenchmarkResult {
+	sizes := []int{1 << 10, 1 << 15, 1 << 20, 1 << 22}
+
+	sum := runner.RunSweepWithSetup("Float Sum", sizes, func(elems int) (func(), func()) {
+		x := randomFloats(elems)
+		return func() {
+			acc := 0.0
+			for _, v := range x {
+				acc += v
+			}
+			floatSink += acc
+		}, nil
+	})
+	dot := runner.RunSweepWithSetup("Float Dot", sizes, func(elems int) (func(), func()) {
+		x := randomFloats(elems)
+		y := randomFloats(elems)
+		return func() {
+			acc := 0.0
+			for i := range x {
+				acc += x[i] * y[i]
+			}
+			floatSink += acc
+		}, nil
+	})
+	// y grows by at most a per op, far from overflowing within a run
+	const a = 0.5
+	axpy := runner.RunSweepWithSetup("Float AXPY", sizes, func(elems int) (func(), func()) {
+		x := randomFloats(elems)
+		y := randomFloats(elems)
+		return func() {
+			y := y[:len(x)] // lets the compiler drop the bounds check on y
+			for i := range x {
+				y[i] += a * x[i]
+			}
+			floatSink += y[len(y)-1]
+		}, nil
+	})
+
+	var results []BenchmarkResult
+	for _, sweep := range []struct {
+		results      []BenchmarkResult
+		flopsPerElem int
+	}{{sum, 1}, {dot, 2}, {axpy, 2}} {
+		for i := range sweep.results {
+			reportFlops(&sweep.results[i], sweep.results[i].Size, sweep.flopsPerElem)
+		}
+		results = append(results, sweep.results...)
+	}
+	return results
+}
+
+// Slice duplication benchmark - copy() vs 手写循环 vs append 复制切片
+// The destination is preallocated so only the copy itself is timed. Each
+// way sweeps the slice length; the x baseline metric compares it against
+// copy at the same size.
+func benchmarkSliceCopy(runner *BenchmarkRunner) []BenchmarkResult {
+	sizes := []int{8, 256, 4096, 65536}
+	sweeps := [][]BenchmarkResult{
+		runner.RunSweepWithSetup("copy()", sizes, func(size int) (func(), func()) {
+			src, dst := randomInts(size), make([]int, size)
+			return func() {
+				copy(dst, src)
+				slicesSink += dst[size-1]
+			}, nil
+		}),
+		runner.RunSweepWithSetup("Manual Loop Copy", sizes, func(size int) (func(), func()) {
+			src, dst := randomInts(size), make([]int, size)
+			return func() {
+				for i := range src {
+					dst[i] = src[i]
+				}
+				slicesSink += dst[size-1]
+			}, nil
+		}),
+		runner.RunSweepWithSetup("append(dst[:0])", sizes, func(size int) (func(), func()) {
+			src, dst := randomInts(size), make([]int, size)
+			return func() {
+				dst = append(dst[:0], src...)
+				slicesSink += dst[size-1]
+			}, nil
+		}),
+	}
+	normalizeSweeps(sweeps, 0)
+	return slices.Concat(sweeps...)
+}
+
+// bitsSink accumulates the bit manipulation results
+var bitsSink uint64
+
+// bitsInputs is the number of words each bit manipulation op processes
+const bitsInputs = 1024
+
+// Naive counterparts of the math/bits functions, written the way code
+// predating math/bits often did
+func naiveOnesCount(x uint64) int {
+	n := 0
+	for ; x != 0; x >>= 1 {
+		n += int(x & 1)
+	}
+	return n
+}
+
+func naiveLeadingZeros(x uint64) int {
+	n := 0
+	for mask := uint64(1) << 63; mask != 0 && x&mask == 0; mask >>= 1 {
+		n++
+	}
+	return n
+}
+
+func naiveReverse(x uint64) uint64 {
+	var r uint64
+	for i := 0; i < 64; i++ {
+		r = r<<1 | x&1
+		x >>= 1
+	}
+	return r
+}
+
+// naiveRotateLeft has a variable shift count and the masking a careful
+// hand-written rotate needs; compilers may still recognize it
+func naiveRotateLeft(x uint64, k int) uint64 {
+	k &= 63
+	return x<<k | x>>((64-k)&63)
+}
+
+// math/bits benchmark - 单指令位操作与朴素循环实现对比
+// Each op processes bitsInputs words. math/bits is the baseline, so x
+// baseline on the naive rows is the speedup math/bits provides.
+func benchmarkMathBits(runner *BenchmarkRunner) []BenchmarkResult {
+	rng := newBenchmarkRand()
+	words := make([]uint64, bitsInputs)
+	for i := range words {
+		// vary the magnitude so LeadingZeros isn't always near zero
+		words[i] = rng.Uint64() >> rng.Intn(64)
+	}
+
+	// Each side loops over the words itself so the calls can be inlined,
+	// as they would be in real code
+	pairs := []struct {
+		name        string
+		bits, naive func(words []uint64) uint64
+	}{
+		{"OnesCount64",
+			func(words []uint64) (acc uint64) {
+				for _, x := range words {
+					acc += uint64(bits.OnesCount64(x))
+				}
+				return acc
+			},
+			func(words []uint64) (acc uint64) {
+				for _, x := range words {
+					acc += uint64(naiveOnesCount(x))
+				}
+				return acc
+			}},
+		{"LeadingZeros64",
+			func(words []uint64) (acc uint64) {
+				for _, x := range words {
+					acc += uint64(bits.LeadingZeros64(x))
+				}
+				return acc
+			},
+			func(words []uint64) (acc uint64) {
+				for _, x := range words {
+					acc += uint64(naiveLeadingZeros(x))
+				}
+				return acc
+			}},
+		{"Reverse64",
+			func(words []uint64) (acc uint64) {
+				for _, x := range words {
+					acc += bits.Reverse64(x)
+				}
+				return acc
+			},
+			func(words []uint64) (acc uint64) {
+				for _, x := range words {
+					acc += naiveReverse(x)
+				}
+				return acc
+			}},
+		{"RotateLeft64",
+			func(words []uint64) (acc uint64) {
+				for _, x := range words {
+					acc += bits.RotateLeft64(x, int(x))
+				}
+				return acc
+			},
+			func(words []uint64) (acc uint64) {
+				for _, x := range words {
+					acc += naiveRotateLeft(x, int(x))
+				}
+				return acc
+			}},
+	}
+
+	var results []BenchmarkResult
+	for _, p := range pairs {
+		if p.bits(words) != p.naive(words) {
+			fmt.Fprintf(os.Stderr, "warning: naive %s disagrees with math/bits\n", p.name)
+		}
+
+		pair := []BenchmarkResult{
+			runner.Run("bits."+p.name, func() {
+				bitsSink += p.bits(words)
+			}),
+			runner.Run("Naive "+p.name, func() {
+				bitsSink += p.naive(words)
+			}),
+		}
+		normalizeResults(pair, 0)
+		results = append(results, pair...)
+	}
+	return results
+}
+
+// mapClearSink keeps the refilled maps observable
+var mapClearSink int
+
+// fillMap inserts keys 0..n-1, the work every map clear case repeats
+func fillMap(m map[int]int, n int) {
+	for i := 0; i < n; i++ {
+		m[i] = i
+	}
+}
+
+// Map clear benchmark - 清空map：delete循环 vs 重新make vs clear内置函数
+// Each op refills the same map and then clears it, so allocs/op shows which
+// strategies keep the map's buckets for reuse. Fill cost is shared by all
+// cases. Each case sweeps the map size; x baseline is relative to clear.
+func benchmarkMapClear(runner *BenchmarkRunner) []BenchmarkResult {
+	sizes := []int{16, 1024, 65536}
+	sweeps := [][]BenchmarkResult{
+		runner.RunSweepWithSetup("clear(m)", sizes, func(size int) (func(), func()) {
+			m := make(map[int]int, size)
+			return func() {
+				fillMap(m, size)
+				mapClearSink += len(m)
+				clear(m)
+			}, nil
+		}),
+		runner.RunSweepWithSetup("Delete Loop", sizes, func(size int) (func(), func()) {
+			m := make(map[int]int, size)
+			return func() {
+				fillMap(m, size)
+				mapClearSink += len(m)
+				for k := range m {
+					delete(m, k)
+				}
+			}, nil
+		}),
+		runner.RunSweepWithSetup("Reassign make", sizes, func(size int) (func(), func()) {
+			m := make(map[int]int, size)
+			return func() {
+				fillMap(m, size)
+				mapClearSink += len(m)
+				m = make(map[int]int, size)
+			}, nil
+		}),
+	}
+	normalizeSweeps(sweeps, 0)
+	return slices.Concat(sweeps...)
+}
+
+// sortSink keeps sorted results observable
+var sortSink int
+
+// sortableInts implements sort.Interface for the sort.Sort case
+type sortableInts []int
+
+func (s sortableInts) Len() int           { return len(s) }
+func (s sortableInts) Less(i, j int) bool { return s[i] < s[j] }
+func (s sortableInts) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// verifySorted warns when a sort benchmark's output isn't ordered, which
+// would mean the timings measure something other than sorting
+func verifySorted(name string, size int, values []int) {
+	if !slices.IsSorted(values) {
+		fmt.Fprintf(os.Stderr, "warning: %s did not sort its input of %d\n", name, size)
+	}
+}
+
+// Sorting API benchmark - sort.Slice（反射）、slices.SortFunc（泛型）与sort.Sort（接口）对比
+// Each API sweeps the slice length over the same seeded input; the
+// slowdown metric compares it against slices.SortFunc at the same size.
+func benchmarkSortAPIs(runner *BenchmarkRunner) []BenchmarkResult {
+	runner.trackAllocs = true
+	sizes := []int{16, 256, 4096}
+	apis := []struct {
+		name string
+		sort func(work []int)
+	}{
+		{"slices.SortFunc", func(work []int) { slices.SortFunc(work, func(a, b int) int { return a - b }) }},
+		{"sort.Slice", func(work []int) { sort.Slice(work, func(i, j int) bool { return work[i] < work[j] }) }},
+		{"sort.Sort", func(work []int) { sort.Sort(sortableInts(work)) }},
+	}
+
+	sweeps := make([][]BenchmarkResult, 0, len(apis))
+	for _, api := range apis {
+		sweeps = append(sweeps, runner.RunSweepWithSetup(api.name, sizes, func(size int) (func(), func()) {
+			input, work := randomInts(size), make([]int, size)
+			return func() {
+				copy(work, input)
+				api.sort(work)
+				sortSink += work[0]
+			}, func() { verifySorted(api.name, size, work) }
+		}))
+	}
+	normalizeSweeps(sweeps, 0)
+	return slices.Concat(sweeps...)
+}
+
+// deferSink receives the deferred cleanup work so it can't be optimized away
+var deferSink uint64
+
+// releaseResource is the cleanup run by the defer benchmarks
+func releaseResource(id int) {
+	deferSink = deferSink*31 + uint64(id)
+	deferSink ^= deferSink >> 17
+}
+
+// deferInLoop is the footgun: every defer is queued until the function
+// returns, and defers inside loops can't be open-coded so each one is
+// heap-allocated.
+func deferInLoop(n int) {
+	for i := 0; i < n; i++ {
+		defer releaseResource(i)
+	}
+}
+
+// deferPerIteration moves the loop body into a function so each defer runs
+// (and is open-coded) at the end of its own iteration
+func deferPerIteration(n int) {
+	for i := 0; i < n; i++ {
+		func() {
+			defer releaseResource(i)
+		}()
+	}
+}
+
+// explicitCleanup releases each resource without defer
+func explicitCleanup(n int) {
+	for i := 0; i < n; i++ {
+		releaseResource(i)
+	}
+}
+
+// Defer placement benchmark - 循环内defer与函数作用域defer、显式清理对比
+// Each way sweeps the iteration count, so the accumulating cost of defers
+// queued in a loop shows in its group.
+func benchmarkDeferPlacement(runner *BenchmarkRunner) []BenchmarkResult {
+	runner.trackAllocs = true
+	counts := []int{10, 100, 1000}
+
+	var results []BenchmarkResult
+	results = append(results, runner.RunSweep("Defer In Loop", counts, deferInLoop)...)
+	results = append(results, runner.RunSweep("Defer Per Iteration", counts, deferPerIteration)...)
+	results = append(results, runner.RunSweep("Explicit Cleanup", counts, explicitCleanup)...)
+	return results
+}
+
+// shape is the interface used by the dispatch benchmark
+type shape interface {
+	area() float64
+}
+
+type rectShape struct{ w, h float64 }
+
+func (r *rectShape) area() float64 { return r.w * r.h }
+
+// areaNoInline is rectShape.area with inlining disabled, isolating the call itself
+//
+//go:noinline
+func (r *rectShape) areaNoInline() float64 { return r.w * r.h }
+
+type circleShape struct{ r float64 }
+
+func (c *circleShape) area() float64 { return math.Pi * c.r * c.r }
+
+// dispatchSink keeps the dispatch benchmark results observable
+var dispatchSink float64
+
+// Interface dispatch benchmark - 接口方法调用、具体类型调用与函数值调用对比
+//
+// The interface slices are filled at runtime from the seeded RNG, so the
+// compiler cannot prove the dynamic type at any call site and every
+// interface call really goes through the itab. If the concrete type were
+// visible (e.g. `var s shape = &rectShape{}` right before the call), the
+// compiler would devirtualize and inline it and the comparison would only
+// measure concrete calls. The monomorphic case holds a single dynamic type
+// (branch predictor friendly); the polymorphic case mixes two at random.
+func benchmarkInterfaceDispatch(runner *BenchmarkRunner) []BenchmarkResult {
+	const calls = 1000
+	rng := newBenchmarkRand()
+
+	rects := make([]rectShape, calls)
+	mono := make([]shape, calls)
+	poly := make([]shape, calls)
+	funcs := make([]func() float64, calls)
+	for i := range rects {
+		rects[i] = rectShape{w: rng.Float64(), h: rng.Float64()}
+		mono[i] = &rects[i]
+		if rng.Intn(2) == 0 {
+			poly[i] = &rects[i]
+		} else {
+			poly[i] = &circleShape{r: rng.Float64()}
+		}
+		funcs[i] = poly[i].area
+	}
+
+	return []BenchmarkResult{
+		runner.Run("Concrete Call (1000 calls)", func() {
+			sum := 0.0
+			for i := range rects {
+				sum += rects[i].area()
+			}
+			dispatchSink += sum
+		}),
+		runner.Run("Concrete NoInline (1000 calls)", func() {
+			sum := 0.0
+			for i := range rects {
+				sum += rects[i].areaNoInline()
+			}
+			dispatchSink += sum
+		}),
+		runner.Run("Interface Mono (1000 calls)", func() {
+			sum := 0.0
+			for _, s := range mono {
+				sum += s.area()
+			}
+			dispatchSink += sum
+		}),
+		runner.Run("Interface Poly (1000 calls)", func() {
+			sum := 0.0
+			for _, s := range poly {
+				sum += s.area()
+			}
+			dispatchSink += sum
+		}),
+		runner.Run("Func Value (1000 calls)", func() {
+			sum := 0.0
+			for _, fn := range funcs {
+				sum += fn()
+			}
+			dispatchSink += sum
+		}),
+	}
+}
+
+// genericStack is a type-parameterized stack; values are stored unboxed
+type genericStack[T any] struct {
+	items []T
+}
+
+func (s *genericStack[T]) push(v T) { s.items = append(s.items, v) }
+
+func (s *genericStack[T]) pop() (T, bool) {
+	var zero T
+	if len(s.items) == 0 {
+		return zero, false
+	}
+	v := s.items[len(s.items)-1]
+	s.items = s.items[:len(s.items)-1]
+	return v, true
+}
+
+func sumGeneric[T ~int | ~int64 | ~float64](s *genericStack[T]) T {
+	var sum T
+	for _, v := range s.items {
+		sum += v
+	}
+	return sum
+}
+
+// boxedStack is the pre-generics equivalent: every non-pointer value pushed
+// is boxed into an interface{}, which usually means a heap allocation
+type boxedStack struct {
+	items []interface{}
+}
+
+func (s *boxedStack) push(v interface{}) { s.items = append(s.items, v) }
+
+func (s *boxedStack) pop() (interface{}, bool) {
+	if len(s.items) == 0 {
+		return nil, false
+	}
+	v := s.items[len(s.items)-1]
+	s.items = s.items[:len(s.items)-1]
+	return v, true
+}
+
+func sumBoxedInts(s *boxedStack) int {
+	sum := 0
+	for _, v := range s.items {
+		sum += v.(int)
+	}
+	return sum
+}
+
+// collectionSink keeps the collection benchmark results observable
+var collectionSink int
+
+// Generics vs interface{} collection benchmark - 泛型容器与interface{}装箱容器对比
+// Each op pushes n values, sums them and pops them all. Inputs come from the
+// seeded RNG and are well above 255, since Go boxes small integers without
+// allocating and that would hide the boxing cost.
+func benchmarkGenericsVsInterface(runner *BenchmarkRunner) []BenchmarkResult {
+	runner.trackAllocs = true // boxing allocations are the main cost
+	sizes := []int{16, 256, 4096}
+
+	results := runner.RunSweepWithSetup("Generic Stack", sizes, func(n int) (func(), func()) {
+		values := randomInts(n)
+		generic := &genericStack[int]{items: make([]int, 0, n)}
+		return func() {
+			for _, v := range values {
+				generic.push(v + 1024)
+			}
+			collectionSink += sumGeneric(generic)
+			for {
+				v, ok := generic.pop()
+				if !ok {
+					break
+				}
+				collectionSink ^= v
+			}
+		}, nil
+	})
+	results = append(results, runner.RunSweepWithSetup("Boxed interface{} Stack", sizes, func(n int) (func(), func()) {
+		values := randomInts(n)
+		boxed := &boxedStack{items: make([]interface{}, 0, n)}
+		return func() {
+			for _, v := range values {
+				boxed.push(v + 1024)
+			}
+			collectionSink += sumBoxedInts(boxed)
+			for {
+				v, ok := boxed.pop()
+				if !ok {
+					break
+				}
+				collectionSink ^= v.(int)
+			}
+		}, nil
+	})...)
+	return results
+}
+
+// lineReadSink keeps the line reading results observable
+var lineReadSink int
+
+// randomLines builds count newline-terminated lines of length bytes each
+func randomLines(count, length int) []byte {
+	rng := newBenchmarkRand()
+	data := make([]byte, 0, count*(length+1))
+	for i := 0; i < count; i++ {
+		for j := 0; j < length; j++ {
+			data = append(data, byte('a'+rng.Intn(26)))
+		}
+		data = append(data, '\n')
+	}
+	return data
+}
+
+// lineReadingBytes is about the input size of every line reading op; the
+// longer the lines, the fewer of them
+const lineReadingBytes = 128 << 10
+
+// Line reading benchmark - bufio.Scanner、bufio.Reader.ReadString与手动扫描对比
+// Lines are read from an in-memory reader so only parsing cost is measured.
+// Each way sweeps the line length; MB/s is reported as a metric to compare
+// across line shapes.
+func benchmarkLineReading(runner *BenchmarkRunner) []BenchmarkResult {
+	runner.trackAllocs = true
+	lengths := []int{16, 128, 1024}
+
+	// Every reader returns the read function for one input
+	readers := []struct {
+		name   string
+		reader func(data []byte) func() (lines, n int)
+	}{
+		{"bufio.Scanner", func(data []byte) func() (int, int) {
+			source := bytes.NewReader(data)
+			return func() (int, int) {
+				source.Reset(data)
+				scanner := bufio.NewScanner(source)
+				lines, n := 0, 0
+				for scanner.Scan() {
+					lines++
+					n += len(scanner.Bytes())
+				}
+				return lines, n
+			}
+		}},
+		{"ReadString", func(data []byte) func() (int, int) {
+			source := bytes.NewReader(data)
+			buffered := bufio.NewReader(source)
+			return func() (int, int) {
+				source.Reset(data)
+				buffered.Reset(source)
+				lines, n := 0, 0
+				for {
+					line, err := buffered.ReadString('\n')
+					if err != nil {
+						break
+					}
+					lines++
+					n += len(line) - 1
+				}
+				return lines, n
+			}
+		}},
+		{"Manual Scan", func(data []byte) func() (int, int) {
+			return func() (int, int) {
+				lines, n := 0, 0
+				for rest := data; len(rest) > 0; {
+					i := bytes.IndexByte(rest, '\n')
+					if i < 0 {
+						i = len(rest)
+					}
+					lines++
+					n += i
+					rest = rest[min(i+1, len(rest)):]
+				}
+				return lines, n
+			}
+		}},
+	}
+
+	var results []BenchmarkResult
+	for _, r := range readers {
+		results = append(results, runner.RunSweepWithSetup(r.name, lengths, func(length int) (func(), func()) {
+			count := lineReadingBytes / length
+			read := r.reader(randomLines(count, length))
+			return func() {
+				lines, n := read()
+				if lines != count {
+					panic(fmt.Sprintf("%s read %d lines, want %d", r.name, lines, count))
+				}
+				lineReadSink += n
+			}, nil
+		})...)
+	}
+	for i := range results {
+		if results[i].Stats.MeanNs > 0 {
+			length := results[i].Size
+			size := lineReadingBytes / length * (length + 1) // randomLines' output
+			results[i].SetMetric("MB/s", float64(size)*1e3/results[i].Stats.MeanNs)
+		}
+	}
+	return results
+}
+
+// regexpPattern is a representative log-scanning pattern (user@host addresses)
+const regexpPattern = `[a-z]+@[a-z]+\.(com|org|net)`
+
+// regexpSink keeps the regexp benchmark results observable
+var regexpSink int
+
+// regexpInput builds length bytes of lowercase words from the seeded RNG,
+// with an address that matches regexpPattern roughly every 64 bytes
+func regexpInput(length int) string {
+	rng := newBenchmarkRand()
+	var sb strings.Builder
+	for sb.Len() < length {
+		if rng.Intn(8) == 0 {
+			sb.WriteString("user@example.com ")
+			continue
+		}
+		for i := rng.Intn(8) + 1; i > 0; i-- {
+			sb.WriteByte(byte('a' + rng.Intn(26)))
+		}
+		sb.WriteByte(' ')
+	}
+	return sb.String()[:length]
+}
+
+// Regexp benchmark - 正则编译的一次性开销与稳态匹配吞吐
+// Compilation is measured on its own; "Recompile+Match" shows the cost of
+// the common mistake of calling regexp.MustCompile inside a hot path. The
+// matching ways sweep the input length in bytes.
+func benchmarkRegexp(runner *BenchmarkRunner) []BenchmarkResult {
+	results := []BenchmarkResult{
+		runner.Run("regexp.MustCompile", func() {
+			regexpSink += regexp.MustCompile(regexpPattern).NumSubexp()
+		}),
+	}
+
+	lengths := []int{64, 1024, 16384}
+	re := regexp.MustCompile(regexpPattern)
+	results = append(results, runner.RunSweepWithSetup("MatchString", lengths, func(length int) (func(), func()) {
+		input := regexpInput(length)
+		return func() {
+			if re.MatchString(input) {
+				regexpSink++
+			}
+		}, nil
+	})...)
+	results = append(results, runner.RunSweepWithSetup("FindAllString", lengths, func(length int) (func(), func()) {
+		input := regexpInput(length)
+		return func() {
+			regexpSink += len(re.FindAllString(input, -1))
+		}, nil
+	})...)
+	results = append(results, runner.RunSweepWithSetup("Recompile+Match", lengths, func(length int) (func(), func()) {
+		input := regexpInput(length)
+		return func() {
+			if regexp.MustCompile(regexpPattern).MatchString(input) {
+				regexpSink++
+			}
+		}, nil
+	})...)
+	return results
+}
+
+// strconvSink keeps parsed and formatted values observable
+var strconvSink int
+
+// strconvInputs is the number of distinct inputs the strconv benchmark
+// cycles through, so one op handles one value without a constant input
+const strconvInputs = 1024
+
+// strconv benchmark - 数字解析/格式化：strconv与fmt对比
+// go_benchmark.go parses its argument with fmt.Sscanf; the fmt rows show
+// what that convenience costs next to strconv.
+func benchmarkStrconv(runner *BenchmarkRunner) []BenchmarkResult {
+	runner.trackAllocs = true
+	rng := newBenchmarkRand()
+	var results []BenchmarkResult
+
+	intRanges := []struct {
+		name  string
+		limit int64
+	}{
+		{"small", 100},
+		{"large", 1 << 62},
+	}
+	for _, r := range intRanges {
+		values := make([]int, strconvInputs)
+		texts := make([]string, strconvInputs)
+		for i := range values {
+			values[i] = int(rng.Int63n(r.limit))
+			texts[i] = strconv.Itoa(values[i])
+		}
+
+		i := 0
+		next := func() int {
+			i = (i + 1) % strconvInputs
+			return i
+		}
+		results = append(results,
+			runner.Run(fmt.Sprintf("strconv.Itoa (%s)", r.name), func() {
+				strconvSink += len(strconv.Itoa(values[next()]))
+			}),
+			runner.Run(fmt.Sprintf("fmt.Sprintf %%d (%s)", r.name), func() {
+				strconvSink += len(fmt.Sprintf("%d", values[next()]))
+			}),
+			runner.Run(fmt.Sprintf("strconv.Atoi (%s)", r.name), func() {
+				n, _ := strconv.Atoi(texts[next()])
+				strconvSink += n
+			}),
+			runner.Run(fmt.Sprintf("strconv.ParseInt (%s)", r.name), func() {
+				n, _ := strconv.ParseInt(texts[next()], 10, 64)
+				strconvSink += int(n)
+			}),
+			runner.Run(fmt.Sprintf("fmt.Sscanf %%d (%s)", r.name), func() {
+				var n int
+				fmt.Sscanf(texts[next()], "%d", &n)
+				strconvSink += n
+			}),
+		)
+	}
+
+	floats := make([]float64, strconvInputs)
+	floatTexts := make([]string, strconvInputs)
+	for i := range floats {
+		floats[i] = rng.NormFloat64() * 1e6
+		floatTexts[i] = strconv.FormatFloat(floats[i], 'g', -1, 64)
+	}
+	i := 0
+	next := func() int {
+		i = (i + 1) % strconvInputs
+		return i
+	}
+	results = append(results,
+		runner.Run("strconv.FormatFloat", func() {
+			strconvSink += len(strconv.FormatFloat(floats[next()], 'g', -1, 64))
+		}),
+		runner.Run("fmt.Sprintf %g", func() {
+			strconvSink += len(fmt.Sprintf("%g", floats[next()]))
+		}),
+		runner.Run("strconv.ParseFloat", func() {
+			f, _ := strconv.ParseFloat(floatTexts[next()], 64)
+			strconvSink += int(f)
+		}),
+		runner.Run("fmt.Sscanf %g", func() {
+			var f float64
+			fmt.Sscanf(floatTexts[next()], "%g", &f)
+			strconvSink += int(f)
+		}),
+	)
+	return results
+}
+
+// timeSink keeps parsed and formatted times observable
+var timeSink int64
+
+// timeInputs is the number of distinct times the time benchmark cycles through
+const timeInputs = 1024
+
+// parseRFC3339UTC is a hand-rolled parser for the fixed layout
+// "2006-01-02T15:04:05Z", the kind of special case hot paths resort to when
+// every input is known to be UTC with second precision
+func parseRFC3339UTC(s string) (time.Time, bool) {
+	if len(s) != 20 || s[4] != '-' || s[7] != '-' || s[10] != 'T' ||
+		s[13] != ':' || s[16] != ':' || s[19] != 'Z' {
+		return time.Time{}, false
+	}
+	ok := true
+	num := func(field string) int {
+		n := 0
+		for _, c := range []byte(field) {
+			if c < '0' || c > '9' {
+				ok = false
+			}
+			n = n*10 + int(c-'0')
+		}
+		return n
+	}
+	t := time.Date(num(s[0:4]), time.Month(num(s[5:7])), num(s[8:10]),
+		num(s[11:13]), num(s[14:16]), num(s[17:19]), 0, time.UTC)
+	return t, ok
+}
+
+// time.Parse/Format benchmark - 常见时间布局的解析与格式化开销
+// Layouts are ordered by complexity. The AppendFormat rows reuse a buffer to
+// show how much of Format's cost is the allocation.
+func benchmarkTimeLayouts(runner *BenchmarkRunner) []BenchmarkResult {
+	runner.trackAllocs = true
+	rng := newBenchmarkRand()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	times := make([]time.Time, timeInputs)
+	for i := range times {
+		times[i] = time.Unix(base+rng.Int63n(365*24*3600), rng.Int63n(1e9)).UTC()
+	}
+	i := 0
+	next := func() int {
+		i = (i + 1) % timeInputs
+		return i
+	}
+
+	var results []BenchmarkResult
+	unixTexts := make([]string, timeInputs)
+	for j, t := range times {
+		unixTexts[j] = strconv.FormatInt(t.Unix(), 10)
+	}
+	results = append(results,
+		runner.Run("Unix Format (strconv)", func() {
+			timeSink += int64(len(strconv.FormatInt(times[next()].Unix(), 10)))
+		}),
+		runner.Run("Unix Parse (strconv)", func() {
+			sec, _ := strconv.ParseInt(unixTexts[next()], 10, 64)
+			timeSink += time.Unix(sec, 0).UnixNano()
+		}),
+	)
+
+	layouts := []struct{ name, layout string }{
+		{"RFC3339", time.RFC3339},
+		{"RFC3339Nano", time.RFC3339Nano},
+		{"Custom ms", "2006-01-02 15:04:05.000"},
+		{"RFC1123Z", time.RFC1123Z},
+	}
+	buf := make([]byte, 0, 64)
+	for _, l := range layouts {
+		texts := make([]string, timeInputs)
+		for j, t := range times {
+			texts[j] = t.Format(l.layout)
+		}
+		results = append(results,
+			runner.Run(fmt.Sprintf("Format %s", l.name), func() {
+				timeSink += int64(len(times[next()].Format(l.layout)))
+			}),
+			runner.Run(fmt.Sprintf("AppendFormat %s", l.name), func() {
+				buf = times[next()].AppendFormat(buf[:0], l.layout)
+				timeSink += int64(len(buf))
+			}),
+			runner.Run(fmt.Sprintf("Parse %s", l.name), func() {
+				t, _ := time.Parse(l.layout, texts[next()])
+				timeSink += t.UnixNano()
+			}),
+		)
+	}
+
+	utcTexts := make([]string, timeInputs)
+	for j, t := range times {
+		utcTexts[j] = t.Format("2006-01-02T15:04:05Z")
+	}
+	results = append(results, runner.Run("Hand-rolled RFC3339 UTC", func() {
+		t, _ := parseRFC3339UTC(utcTexts[next()])
+		timeSink += t.Unix()
+	}))
+	return results
+}
+
+// contextSink counts contexts observed as done, keeping the checks live
+var contextSink int
+
+// handleWithContext stands in for request work that finishes well before
+// its deadline and checks the context once
+func handleWithContext(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+		contextSink++
+	default:
+	}
+}
+
+// context.WithTimeout lifecycle benchmark - 每请求创建超时context的完整开销
+// The work always finishes before the timeout, the common case in servers:
+// the cost is creating the context, arming its timer and stopping it again
+// in cancel. WithCancel has no timer, so the gap between the two is the
+// timer's share; the reused context is the floor.
+func benchmarkContextTimeout(runner *BenchmarkRunner) []BenchmarkResult {
+	runner.trackAllocs = true
+	parent := context.Background()
+
+	results := []BenchmarkResult{
+		runner.Run("Reused Context", func() {
+			handleWithContext(parent)
+		}),
+		runner.Run("context.WithCancel", func() {
+			ctx, cancel := context.WithCancel(parent)
+			handleWithContext(ctx)
+			cancel()
+		}),
+		runner.Run("context.WithTimeout", func() {
+			ctx, cancel := context.WithTimeout(parent, time.Second)
+			handleWithContext(ctx)
+			cancel()
+		}),
+		runner.Run("context.WithTimeout (defer)", func() {
+			func() {
+				ctx, cancel := context.WithTimeout(parent, time.Second)
+				defer cancel()
+				handleWithContext(ctx)
+			}()
+		}),
+	}
+
+	// Nested timeouts, as when a handler bounds a downstream call tighter
+	// than the request deadline
+	requestCtx, cancelRequest := context.WithTimeout(parent, time.Hour)
+	results = append(results, runner.Run("Nested WithTimeout", func() {
+		ctx, cancel := context.WithTimeout(requestCtx, time.Second)
+		handleWithContext(ctx)
+		cancel()
+	}))
+	cancelRequest()
+
+	normalizeResults(results, 0)
+	return results
+}
+
+// codecSink keeps encoded sizes and decoded values observable
+var codecSink int
+
+// sampleBenchmarkResult is the representative struct for the codec
+// benchmark: a realistic result as this program writes it
+func sampleBenchmarkResult() BenchmarkResult {
+	result := BenchmarkResult{
+		Name:            "Sample Benchmark (4096)",
+		Iterations:      6300,
+		TotalTimeNs:     1.02e8,
+		Kind:            KindCPUBound,
+		StopReason:      StopTimeBudget,
+		MonotonicTiming: true,
+		AllocsMeasured:  true,
+		AllocBytesPerOp: 4096,
+		AllocsPerOp:     2,
+		CPUTimeNs:       1.01e8,
+		Parallelism:     0.99,
+		GCCount:         3,
+	}
+	result.WarmupNs = randomFloats(10)
+	result.Stats.Calculate(randomFloats(1000))
+	result.SetMetric("MB/s", 812.5)
+	result.SetMetric(relativeMetric, 1.37)
+	return result
+}
+
+// reportCodecThroughput records the encoded message size and MB/s of it
+// processed per op
+func reportCodecThroughput(result *BenchmarkResult, size int) {
+	result.SetMetric("B/msg", float64(size))
+	if result.Stats.MeanNs > 0 {
+		result.SetMetric("MB/s", float64(size)*1e3/result.Stats.MeanNs)
+	}
+}
+
+// gob vs JSON benchmark - 以BenchmarkResult为样本对比encoding/gob与encoding/json
+// gob sends a type descriptor once per stream, so its steady state is
+// measured on a long-lived Encoder/Decoder and a fresh Encoder per message
+// shows the per-stream cost. The process-wide first use, which also builds
+// gob's type info, can only be timed once and is reported as a metric.
+func benchmarkGobVsJSON(runner *BenchmarkRunner) []BenchmarkResult {
+	runner.trackAllocs = true
+	names := []string{"gob Encode (new Encoder)", "gob Encode (stream)", "gob Decode (stream)",
+		"json.Marshal", "json Encode (stream)", "json.Unmarshal"}
+	if runner.skipsAll(names...) {
+		// Above all a dry run must not use gob, or the first use measured
+		// by the real run isn't the first
+		results := make([]BenchmarkResult, len(names))
+		for i, name := range names {
+			results[i] = runner.Run(name, nil)
+		}
+		return results
+	}
+	sample := sampleBenchmarkResult()
+
+	firstStart := time.Now()
+	if err := gob.NewEncoder(io.Discard).Encode(&sample); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: gob cannot encode the sample: %v\n", err)
+		return nil
+	}
+	firstUse := time.Since(firstStart)
+
+	// A primed stream: first holds the type descriptor and a value, msg
+	// only a value, which is what a long-lived connection sends per message
+	var stream bytes.Buffer
+	streamEnc := gob.NewEncoder(&stream)
+	streamEnc.Encode(&sample)
+	first := slices.Clone(stream.Bytes())
+	stream.Reset()
+	streamEnc.Encode(&sample)
+	msg := slices.Clone(stream.Bytes())
+
+	jsonMsg, _ := json.Marshal(&sample)
+
+	var buf bytes.Buffer
+	gobFresh := runner.Run(names[0], func() {
+		buf.Reset()
+		gob.NewEncoder(&buf).Encode(&sample)
+		codecSink += buf.Len()
+	})
+	reportCodecThroughput(&gobFresh, len(first))
+	gobFresh.SetMetric("first use ns", float64(firstUse.Nanoseconds()))
+
+	gobEncode := runner.Run(names[1], func() {
+		streamEnc.Encode(&sample)
+		codecSink += stream.Len()
+		stream.Reset()
+	})
+	reportCodecThroughput(&gobEncode, len(msg))
+
+	var in bytes.Buffer
+	dec := gob.NewDecoder(&in)
+	in.Write(first)
+	var decoded BenchmarkResult
+	dec.Decode(&decoded)
+	gobDecode := runner.Run(names[2], func() {
+		in.Write(msg)
+		decoded = BenchmarkResult{}
+		dec.Decode(&decoded)
+		codecSink += decoded.Iterations
+	})
+	reportCodecThroughput(&gobDecode, len(msg))
+
+	jsonEncode := runner.Run(names[3], func() {
+		data, _ := json.Marshal(&sample)
+		codecSink += len(data)
+	})
+	reportCodecThroughput(&jsonEncode, len(jsonMsg))
+
+	jsonEnc := json.NewEncoder(&buf)
+	jsonStream := runner.Run(names[4], func() {
+		buf.Reset()
+		jsonEnc.Encode(&sample)
+		codecSink += buf.Len()
+	})
+	reportCodecThroughput(&jsonStream, len(jsonMsg))
+
+	jsonDecode := runner.Run(names[5], func() {
+		decoded = BenchmarkResult{}
+		json.Unmarshal(jsonMsg, &decoded)
+		codecSink += decoded.Iterations
+	})
+	reportCodecThroughput(&jsonDecode, len(jsonMsg))
+
+	if decoded.Name != sample.Name || !reflect.DeepEqual(decoded.Stats, sample.Stats) {
+		fmt.Fprintln(os.Stderr, "warning: gob vs JSON: decoded result differs from the sample")
+	}
+	return []BenchmarkResult{gobFresh, gobEncode, gobDecode, jsonEncode, jsonStream, jsonDecode}
+}
+
+// escapePoint is the value the escape analysis benchmark moves around
+type escapePoint struct {
+	X, Y, Z float64
+}
+
+// escapeSink holds what the escaping variants publish, and escapeSum the
+// values read back so nothing is dead code
+var (
+	escapeSink any
+	escapeSum  float64
+)
+
+// The helpers below are noinline on purpose: inlined into the benchmark
+// closure, the compiler would see the whole lifetime of each value and
+// could keep even the "escaping" ones on the stack. Run
+// `go build -gcflags=-m .` to see its decisions.
+
+// newPointValue returns by value: the result is copied to the caller's stack
+//
+//go:noinline
+func newPointValue(x float64) escapePoint {
+	return escapePoint{x, x * 2, x * 3}
+}
+
+// newPointPointer returns the address of a local, which outlives the call
+// and so is moved to the heap ("moved to heap: p")
+//
+//go:noinline
+func newPointPointer(x float64) *escapePoint {
+	p := escapePoint{x, x * 2, x * 3}
+	return &p
+}
+
+// fillPoint writes through a pointer it doesn't retain, so the caller's
+// value stays on the caller's stack ("p does not escape")
+//
+//go:noinline
+func fillPoint(p *escapePoint, x float64) {
+	p.X, p.Y, p.Z = x, x*2, x*3
+}
+
+// publishPoint stores its argument in a global, so any pointer passed in
+// escapes ("leaking param: p")
+//
+//go:noinline
+func publishPoint(p *escapePoint) {
+	escapeSink = p
+}
+
+// boxPoint converts a value to an interface that is retained, which copies
+// it to the heap
+//
+//go:noinline
+func boxPoint(p escapePoint) {
+	escapeSink = p
+}
+
+// pointSlice allocates a slice whose size the compiler can't prove small
+// and constant, which always goes to the heap
+//
+//go:noinline
+func pointSlice(n int) float64 {
+	points := make([]escapePoint, n)
+	points[n-1].X = float64(n)
+	return points[n-1].X
+}
+
+// Escape analysis benchmark - 演示逃逸分析：同一操作在栈上与堆上的分配差异
+// Names carry the expected placement; a warning is printed when the
+// measured allocations disagree, e.g. after a compiler change.
+func benchmarkEscapeAnalysis(runner *BenchmarkRunner) []BenchmarkResult {
+	runner.trackAllocs = true
+	x := 1.5
+	cases := []struct {
+		name string
+		heap bool
+		run  func()
+	}{
+		{"Return Value (stack)", false, func() {
+			p := newPointValue(x)
+			escapeSum += p.Z
+		}},
+		{"Return Pointer (heap)", true, func() {
+			p := newPointPointer(x)
+			escapeSum += p.Z
+		}},
+		{"Out Param (stack)", false, func() {
+			var p escapePoint
+			fillPoint(&p, x)
+			escapeSum += p.Z
+		}},
+		{"Publish Pointer (heap)", true, func() {
+			var p escapePoint
+			fillPoint(&p, x)
+			publishPoint(&p)
+		}},
+		{"Interface Box (heap)", true, func() {
+			boxPoint(escapePoint{x, x, x})
+		}},
+		{"Fixed Array (stack)", false, func() {
+			var points [16]escapePoint
+			fillPoint(&points[15], x)
+			escapeSum += points[15].Z
+		}},
+		{"Variable make (heap)", true, func() {
+			escapeSum += pointSlice(16)
+		}},
+	}
+
+	var results []BenchmarkResult
+	for _, c := range cases {
+		result := runner.Run("Escape "+c.name, c.run)
+		if result.AllocsMeasured && (result.AllocsPerOp > 0) != c.heap {
+			fmt.Fprintf(os.Stderr, "warning: %s: measured %.2f allocs/op, the compiler decided differently than expected\n",
+				result.Name, result.AllocsPerOp)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// Sinks for the unsafe conversion benchmark. Storing into package-level
+// variables makes the converted values escape, so the safe conversions can't
+// use a stack buffer and the zero-copy ones can't be optimized away.
+var (
+	unsafeStringSink string
+	unsafeBytesSink  []byte
+	unsafeSink       int
+)
+
+// reportAllocsSaved records on the unsafe result how many bytes per op it
+// avoided allocating compared to the safe one, the headline of the comparison
+func reportAllocsSaved(safe BenchmarkResult, unsafeResult *BenchmarkResult) {
+	unsafeResult.SetMetric("B/op saved", safe.AllocBytesPerOp-unsafeResult.AllocBytesPerOp)
+}
+
+// unsafe conversion benchmark - 演示unsafe零拷贝转换的代价与风险取舍
+// The unsafe variants are only correct while nobody mutates the shared
+// memory: a string built with unsafe.String changes if its bytes do, and
+// writing through unsafe.Slice of a string is undefined behavior. This
+// measures what the optimization buys, not an endorsement of it.
+func benchmarkUnsafeConversions(runner *BenchmarkRunner) []BenchmarkResult {
+	runner.trackAllocs = true
+	var results []BenchmarkResult
+	for _, size := range []int{16, 1024} {
+		data := make([]byte, size)
+		newBenchmarkRand().Read(data)
+		str := string(data)
+		words := make([]uint32, size/4)
+		for i := range words {
+			words[i] = binary.LittleEndian.Uint32(data[i*4:])
+		}
+
+		safe := runner.Run(fmt.Sprintf("string([]byte) (%dB)", size), func() {
+			unsafeStringSink = string(data)
+			unsafeSink += int(unsafeStringSink[size-1])
+		})
+		zeroCopy := runner.Run(fmt.Sprintf("unsafe.String (%dB)", size), func() {
+			unsafeStringSink = unsafe.String(unsafe.SliceData(data), len(data))
+			unsafeSink += int(unsafeStringSink[size-1])
+		})
+		reportAllocsSaved(safe, &zeroCopy)
+		results = append(results, safe, zeroCopy)
+
+		safe = runner.Run(fmt.Sprintf("[]byte(string) (%dB)", size), func() {
+			unsafeBytesSink = []byte(str)
+			unsafeSink += int(unsafeBytesSink[size-1])
+		})
+		zeroCopy = runner.Run(fmt.Sprintf("unsafe.Slice(string) (%dB)", size), func() {
+			unsafeBytesSink = unsafe.Slice(unsafe.StringData(str), len(str))
+			unsafeSink += int(unsafeBytesSink[size-1])
+		})
+		reportAllocsSaved(safe, &zeroCopy)
+		results = append(results, safe, zeroCopy)
+
+		// Reinterpreting []uint32 as bytes depends on host endianness, which
+		// the encoding/binary copy spells out
+		safe = runner.Run(fmt.Sprintf("[]uint32 encode copy (%dB)", size), func() {
+			buf := make([]byte, len(words)*4)
+			for i, w := range words {
+				binary.LittleEndian.PutUint32(buf[i*4:], w)
+			}
+			unsafeBytesSink = buf
+			unsafeSink += int(buf[size-1])
+		})
+		zeroCopy = runner.Run(fmt.Sprintf("[]uint32 unsafe view (%dB)", size), func() {
+			unsafeBytesSink = unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(words))), len(words)*4)
+			unsafeSink += int(unsafeBytesSink[size-1])
+		})
+		reportAllocsSaved(safe, &zeroCopy)
+		results = append(results, safe, zeroCopy)
+	}
+	return results
+}
+
+// logRequest is the representative structured log call of the slog benchmark
+func logRequest(logger *slog.Logger, latency time.Duration) {
+	logger.Info("request handled",
+		"method", "GET",
+		"path", "/api/v1/users",
+		"status", 200,
+		"latency", latency,
+		"bytes", 5120,
+	)
+}
+
+// log/slog benchmark - 不同handler下结构化日志调用的开销
+// Handlers write to io.Discard so formatting is measured, not IO. The
+// disabled level case is reported on its own: it is the most common call in
+// production and should cost next to nothing.
+func benchmarkSlogHandlers(runner *BenchmarkRunner) []BenchmarkResult {
+	runner.trackAllocs = true
+	latency := 1500 * time.Microsecond
+	handlers := []struct {
+		name    string
+		handler slog.Handler
+	}{
+		{"slog TextHandler", slog.NewTextHandler(io.Discard, nil)},
+		{"slog JSONHandler", slog.NewJSONHandler(io.Discard, nil)},
+		{"slog DiscardHandler", slog.DiscardHandler},
+	}
+
+	var results []BenchmarkResult
+	for _, h := range handlers {
+		logger := slog.New(h.handler)
+		results = append(results, runner.Run(h.name, func() {
+			logRequest(logger, latency)
+		}))
+	}
+
+	disabled := slog.New(slog.NewJSONHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	results = append(results, runner.Run("slog Disabled Level", func() {
+		logRequest(disabled, latency)
+	}))
+	return results
+}
+
+// System information
+type SystemInfo struct {
+	GoVersion    string `json:"go_version"`
+	OS           string `json:"os"`
+	Arch         string `json:"arch"`
+	NumCPU       int    `json:"num_cpu"`
+	NumGoroutine int    `json:"num_goroutine"`
+	Timestamp    int64  `json:"timestamp"`
+	RunID        string `json:"run_id,omitempty"`
+
+	// Runtime settings that make runs incomparable when they differ, e.g.
+	// GOMAXPROCS=1 vs 16. GOGC is the environment value, "100" when unset.
+	GOMAXPROCS int    `json:"gomaxprocs,omitempty"`
+	GOGC       string `json:"gogc,omitempty"`
+	CgoEnabled bool   `json:"cgo_enabled"`
+	Hostname   string `json:"hostname,omitempty"`
+
+	// CPU model name and base frequency, best effort; see cpuInfo
+	CPUModel string  `json:"cpu_model,omitempty"`
+	CPUMHz   float64 `json:"cpu_mhz,omitempty"`
+}
+
+// sameCPU reports whether info and other ran on the same CPU model at the
+// same frequency. Unknown values on either side count as the same, so files
+// from before these were recorded don't warn.
+func (info SystemInfo) sameCPU(other SystemInfo) bool {
+	if info.CPUModel != "" && other.CPUModel != "" && info.CPUModel != other.CPUModel {
+		return false
+	}
+	return info.CPUMHz == 0 || other.CPUMHz == 0 || info.CPUMHz == other.CPUMHz
+}
+
+// newRunID returns an ID unique to one run of the suite: its UTC start time,
+// readable and sortable, plus random bits so concurrent runs can't collide
+func newRunID() string {
+	suffix := make([]byte, 4)
+	if _, err := cryptorand.Read(suffix); err != nil {
+		// the time alone still tells successive runs apart
+		return time.Now().UTC().Format("20060102T150405.000000")
+	}
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// artifactPath embeds runID in name before its extension, e.g.
+// go_benchmark_results-<run id>.json, so artifacts of one run group together.
+// An empty runID leaves name unchanged.
+func artifactPath(name, runID string) string {
+	if runID == "" {
+		return name
+	}
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "-" + runID + ext
+}
+
+// BenchmarkSuite contains all benchmark results and system info
+type BenchmarkSuite struct {
+	SystemInfo SystemInfo        `json:"system_info"`
+	Results    []BenchmarkResult `json:"results"`
+
+	// ShuffleSeed is set when benchmarks ran in shuffled order (-shuffle)
+	ShuffleSeed *int64 `json:"shuffle_seed,omitempty"`
+
+	// Labels are free-form key/value tags from -label, such as the build
+	// flags or the machine role, carried into every exported format
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Groups aggregates the results that belong to a group
+	Groups []GroupSummary `json:"groups,omitempty"`
+
+	// Tags holds the geometric means of each tag's results
+	Tags []TagSummary `json:"tags,omitempty"`
+
+	// Geometric means over the results, see GeoMeanMeanNs; omitted when
+	// there is nothing to average
+	MeanNsGeoMean     float64 `json:"geomean_mean_ns,omitempty"`
+	ThroughputGeoMean float64 `json:"geomean_throughput_ops_sec,omitempty"`
+}
+
+// geoMean returns the geometric mean of the positive values, NaN if there
+// are none. Zeros (e.g. skipped results) would collapse the product, so they
+// are left out rather than counted.
+func geoMean(values []float64) float64 {
+	sum, n := 0.0, 0
+	for _, v := range values {
+		if v > 0 {
+			sum += math.Log(v)
+			n++
+		}
+	}
+	if n == 0 {
+		return math.NaN()
+	}
+	return math.Exp(sum / float64(n))
+}
+
+// GeoMeanMeanNs is the geometric mean of the results' mean times, the usual
+// single number for a suite: every benchmark weighs the same, however slow
+func (s BenchmarkSuite) GeoMeanMeanNs() float64 {
+	means := make([]float64, len(s.Results))
+	for i, result := range s.Results {
+		means[i] = result.Stats.MeanNs
+	}
+	return geoMean(means)
+}
+
+// GeoMeanThroughput is the geometric mean of the results' throughputs
+func (s BenchmarkSuite) GeoMeanThroughput() float64 {
+	throughputs := make([]float64, len(s.Results))
+	for i := range s.Results {
+		throughputs[i] = s.Results[i].Throughput()
+	}
+	return geoMean(throughputs)
+}
+
+// WorstCV returns the result with the highest coefficient of variation and
+// that CV, so CI can fail or retry a run whose noisiest benchmark is too
+// noisy; ok is false for a suite without results
+func (s BenchmarkSuite) WorstCV() (worst BenchmarkResult, cv float64, ok bool) {
+	for _, result := range s.Results {
+		if !ok || result.Stats.CV() > cv {
+			worst, cv, ok = result, result.Stats.CV(), true
+		}
+	}
+	return worst, cv, ok
+}
+
+// printScalingReport prints the speedup curve of every RunScaling group and
+// the level where its efficiency drops below scalingEfficiencyFloor
+func printScalingReport(results []BenchmarkResult) {
+	var groups []string
+	levels := make(map[string][]BenchmarkResult)
+	for _, result := range results {
+		if result.Workers == 0 {
+			continue
+		}
+		if _, ok := levels[result.Group]; !ok {
+			groups = append(groups, result.Group)
+		}
+		levels[result.Group] = append(levels[result.Group], result)
+	}
+	if len(groups) == 0 {
+		return
+	}
+
+	fmt.Println("\n=== Parallel Scaling ===")
+	for _, group := range groups {
+		fmt.Printf("%s:\n", group)
+		for _, result := range levels[group] {
+			speedup, ok := result.Metrics[speedupMetric]
+			if !ok {
+				fmt.Printf("  p=%-4d %14s ops/sec\n", result.Workers, formatThroughput(result.Throughput()))
+				continue
+			}
+			fmt.Printf("  p=%-4d %14s ops/sec  speedup %5.2fx  efficiency %3.0f%%\n", result.Workers,
+				formatThroughput(result.Throughput()), speedup, result.Metrics[efficiencyMetric]*100)
+		}
+		if limit := ScalingLimit(levels[group]); limit > 0 {
+			fmt.Printf("  efficiency falls below %.0f%% at p=%d\n", scalingEfficiencyFloor*100, limit)
+		} else {
+			fmt.Printf("  efficiency stays above %.0f%% at every level\n", scalingEfficiencyFloor*100)
+		}
+	}
+}
+
+// printGeoMean prints the suite's headline numbers, if it has any
+func printGeoMean(suite BenchmarkSuite) {
+	if suite.MeanNsGeoMean == 0 {
+		return
+	}
+	fmt.Printf("\nGeometric mean over %d results: %s/op, %.2f ops/sec\n",
+		len(suite.Results), formatDuration(suite.MeanNsGeoMean), suite.ThroughputGeoMean)
+}
+
+// TagSummary holds the geometric means over the results of a tag, so a
+// change can be told per area: compute got faster, io didn't move
+type TagSummary struct {
+	Name              string  `json:"name"`
+	Results           int     `json:"results"`
+	MeanNsGeoMean     float64 `json:"geomean_mean_ns,omitempty"`
+	ThroughputGeoMean float64 `json:"geomean_throughput_ops_sec,omitempty"`
+}
+
+// summarizeTags computes the geometric means of each tag's results, sorted
+// by tag. A result with several tags counts towards each of them.
+func summarizeTags(results []BenchmarkResult) []TagSummary {
+	members := make(map[string][]BenchmarkResult)
+	for _, result := range results {
+		for _, tag := range result.Tags {
+			members[tag] = append(members[tag], result)
+		}
+	}
+	tags := make([]TagSummary, 0, len(members))
+	for tag, tagged := range members {
+		sub := BenchmarkSuite{Results: tagged}
+		summary := TagSummary{Name: tag, Results: len(tagged)}
+		// NaN can't be encoded as JSON, the fields stay unset instead
+		if g := sub.GeoMeanMeanNs(); !math.IsNaN(g) {
+			summary.MeanNsGeoMean = g
+		}
+		if g := sub.GeoMeanThroughput(); !math.IsNaN(g) {
+			summary.ThroughputGeoMean = g
+		}
+		tags = append(tags, summary)
+	}
+	slices.SortFunc(tags, func(a, b TagSummary) int { return strings.Compare(a.Name, b.Name) })
+	return tags
+}
+
+// tagBaselineChange is the change of tag's results against the -baseline
+// as the geometric mean of their mean-time ratios, over the results the
+// baseline has too; ok is false when there are none. Comparing like with
+// like keeps benchmarks added since the baseline from moving the number.
+func tagBaselineChange(tag string, results []BenchmarkResult) (change float64, ok bool) {
+	var ratios []float64
+	for _, result := range results {
+		base := summaryBaseline[result.Name]
+		if !slices.Contains(result.Tags, tag) || !(base > 0) || !(result.Stats.MeanNs > 0) {
+			continue
+		}
+		ratios = append(ratios, result.Stats.MeanNs/base)
+	}
+	if len(ratios) == 0 {
+		return 0, false
+	}
+	return geoMean(ratios) - 1, true
+}
+
+// printTagSummary prints the geometric means of every tag and, against a
+// -baseline, how each tag's times changed
+func printTagSummary(suite BenchmarkSuite) {
+	if len(suite.Tags) == 0 {
+		return
+	}
+	fmt.Println("\n=== Geometric Mean by Tag ===")
+	if summaryBaseline != nil {
+		fmt.Printf("%-14s %8s %15s %14s %9s\n", "Tag", "Results", "Mean Time", "Throughput", "vs base")
+	} else {
+		fmt.Printf("%-14s %8s %15s %14s\n", "Tag", "Results", "Mean Time", "Throughput")
+	}
+	for _, tag := range suite.Tags {
+		line := fmt.Sprintf("%-14s %8d %15s %14s", tag.Name, tag.Results,
+			formatDuration(tag.MeanNsGeoMean), formatThroughput(tag.ThroughputGeoMean))
+		if summaryBaseline != nil {
+			if change, ok := tagBaselineChange(tag.Name, suite.Results); ok {
+				line += " " + formatChange(change)
+			} else {
+				line += fmt.Sprintf(" %9s", "new")
+			}
+		}
+		fmt.Println(line)
+	}
+}
+
+func printSystemInfo() {
+	fmt.Println("\n=== System Information ===")
+	fmt.Printf("Go Version: %s\n", runtime.Version())
+	fmt.Printf("OS/Arch: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	fmt.Printf("CPU Cores: %d\n", runtime.NumCPU())
+	fmt.Printf("GOMAXPROCS: %d\n", runtime.GOMAXPROCS(0))
+	fmt.Printf("Clock Resolution: %s\n", clockResolution())
+	if model, mhz := cpuInfo(); mhz > 0 {
+		fmt.Printf("CPU Model: %s (%.0f MHz)\n", model, mhz)
+	} else if model != "" {
+		fmt.Printf("CPU Model: %s\n", model)
+	}
+	fmt.Printf("Goroutines: %d\n", runtime.NumGoroutine())
+	fmt.Println("==========================")
+}
+
+func printBenchmarkHeader() {
+	fmt.Println("\n=== Go Performance Benchmarks ===")
+}
+
+// failedBenchmarks returns the names of the results that panicked
+func failedBenchmarks(results []BenchmarkResult) []string {
+	var failed []string
+	for _, result := range results {
+		if result.Failed {
+			failed = append(failed, result.Name)
+		}
+	}
+	return failed
+}
+
+// printStopReasonSummary points out benchmarks that hit the iteration cap
+// before their time budget, which usually calls for retuning the runner,
+// and ones whose -budget share was too small for the minimum samples
+func printStopReasonSummary(results []BenchmarkResult) {
+	var capped, short []string
+	for _, result := range results {
+		switch result.StopReason {
+		case StopMaxIterations:
+			capped = append(capped, result.Name)
+		case StopSuiteBudget:
+			short = append(short, result.Name)
+		}
+	}
+	if len(short) > 0 {
+		fmt.Printf("\n%d of %d benchmarks ran out of their -budget share before the minimum samples—"+
+			"their percentiles rest on few samples; consider a larger budget:\n", len(short), len(results))
+		for _, name := range short {
+			fmt.Printf("  %s\n", name)
+		}
+	}
+	if len(capped) == 0 {
+		return
+	}
+
+	fmt.Printf("\n%d of %d benchmarks capped at max iterations before their time budget—"+
+		"consider raising maxIterations or reducing work per call:\n", len(capped), len(results))
+	for _, name := range capped {
+		fmt.Printf("  %s\n", name)
+	}
+}
+
+// Reliability gate thresholds for -fail-on-unreliable. A sample must span
+// resolutionSampleFactor clock steps to be meaningful, and a call under
+// optimizedAwayNs can't have done real work, so its body was likely
+// eliminated by the compiler.
+const (
+	resolutionSampleFactor = 10
+	optimizedAwayNs        = 0.5
+)
+
+// unreliableReasons lists why result's numbers shouldn't be trusted, empty
+// when it passes every reliability check. maxCV is the highest acceptable
+// coefficient of variation (stddev / mean).
+func unreliableReasons(result BenchmarkResult, maxCV float64) []string {
+	if result.Failed {
+		return []string{"failed: " + result.Error}
+	}
+	var reasons []string
+	if result.Stats.MeanNs > 0 {
+		if cv := result.Stats.CV(); cv > maxCV {
+			reasons = append(reasons, fmt.Sprintf("CV %.2f above %.2f", cv, maxCV))
+		}
+	}
+	sampleNs := result.Stats.MedianNs * float64(max(result.BatchSize, 1))
+	if resolution := float64(clockResolution().Nanoseconds()); sampleNs < resolutionSampleFactor*resolution {
+		reasons = append(reasons, fmt.Sprintf("%s samples below %dx clock resolution (%s), try -auto-batch",
+			formatDuration(sampleNs), resolutionSampleFactor, formatDuration(resolution)))
+	}
+	if result.Iterations > 0 && result.Stats.MedianNs < optimizedAwayNs {
+		reasons = append(reasons, fmt.Sprintf("%s/op, likely optimized away", formatDuration(result.Stats.MedianNs)))
+	}
+	if result.NegativeSamples > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d negative samples", result.NegativeSamples))
+	}
+	return reasons
+}
+
+// checkReliability prints every result failing a reliability check and
+// reports whether all of them passed
+func checkReliability(results []BenchmarkResult, maxCV float64) bool {
+	width := resultNameWidth(results)
+	failed := 0
+	for _, result := range results {
+		reasons := unreliableReasons(result, maxCV)
+		if len(reasons) == 0 {
+			continue
+		}
+		if failed == 0 {
+			fmt.Println("\n=== Unreliable Benchmarks ===")
+		}
+		failed++
+		fmt.Printf("  %-*s %s\n", width, result.Name, strings.Join(reasons, "; "))
+	}
+	if failed > 0 {
+		fmt.Printf("%d of %d benchmarks failed the reliability check\n", failed, len(results))
+	}
+	return failed == 0
+}
+
+func printBenchmarkFooter() {
+	fmt.Println(strings.Repeat("=", summaryNameWidth+summaryRuleWidth))
+	fmt.Println("\nBenchmark completed successfully.")
+	fmt.Println("Note: Results may vary based on system load and hardware configuration.")
+}
+
+// newSystemInfo describes the current system
+func newSystemInfo() SystemInfo {
+	info := SystemInfo{
+		GoVersion:    runtime.Version(),
+		OS:           runtime.GOOS,
+		Arch:         runtime.GOARCH,
+		NumCPU:       runtime.NumCPU(),
+		NumGoroutine: runtime.NumGoroutine(),
+		Timestamp:    time.Now().Unix(),
+		GOMAXPROCS:   runtime.GOMAXPROCS(0),
+		GOGC:         cmp.Or(os.Getenv("GOGC"), "100"),
+		CgoEnabled:   cgoEnabled(),
+		Hostname:     hostname(),
+	}
+	info.CPUModel, info.CPUMHz = cpuInfo()
+	return info
+}
+
+// cgoEnabled reports whether the binary was built with cgo, from the
+// CGO_ENABLED setting recorded in its build info
+func cgoEnabled() bool {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return false
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "CGO_ENABLED" {
+			return setting.Value == "1"
+		}
+	}
+	return false
+}
+
+// hostname returns the machine's host name, or "" if it can't be read
+func hostname() string {
+	name, _ := os.Hostname()
+	return name
+}
+
+// newBenchmarkSuite wraps results with info about the system they ran on
+func newBenchmarkSuite(info SystemInfo, results []BenchmarkResult) BenchmarkSuite {
+	suite := BenchmarkSuite{SystemInfo: info, Results: results}
+	suite.summarize()
+	return suite
+}
+
+// summarize sets the suite-level aggregates of the results
+func (s *BenchmarkSuite) summarize() {
+	s.Groups = summarizeGroups(s.Results)
+	s.Tags = summarizeTags(s.Results)
+	// NaN can't be encoded as JSON, the fields stay unset instead
+	if g := s.GeoMeanMeanNs(); !math.IsNaN(g) {
+		s.MeanNsGeoMean = g
+	}
+	if g := s.GeoMeanThroughput(); !math.IsNaN(g) {
+		s.ThroughputGeoMean = g
+	}
+}
+
+// ResultStreamWriter writes a results file incrementally, in the same
+// format as a marshaled BenchmarkSuite: system_info first, then each result
+// as it is added, then the suite aggregates on Close. Only a small summary
+// of every result is kept, and a file being written can be read up to the
+// last complete result. A result that can't be encoded (e.g. a NaN metric)
+// is left out and the others are still written; Close reports it.
+type ResultStreamWriter struct {
+	w      *bufio.Writer
+	header BenchmarkSuite // written before the results
+	opened bool
+	added  []BenchmarkResult
+	err    error // a write error, after which nothing more is written
+
+	// dropped is the first result left out, if none failed to write before
+	dropped error
+}
+
+// NewResultStreamWriter returns a writer for a results file on w. header
+// supplies the fields written before the results, system_info and
+// shuffle_seed; its Results are ignored.
+func NewResultStreamWriter(w io.Writer, header BenchmarkSuite) *ResultStreamWriter {
+	return &ResultStreamWriter{w: bufio.NewWriter(w), header: header}
+}
+
+// write appends the JSON of v to the output, indented to depth
+func (sw *ResultStreamWriter) write(prefix string, v any, depth int) {
+	if sw.err != nil {
+		return
+	}
+	data, err := json.MarshalIndent(v, strings.Repeat("  ", depth), "  ")
+	if err != nil {
+		sw.err = err
+		return
+	}
+	sw.w.WriteString(prefix)
+	sw.w.Write(data)
+}
+
+func (sw *ResultStreamWriter) open() {
+	if sw.opened {
+		return
+	}
+	sw.opened = true
+	sw.write("{\n  \"system_info\": ", sw.header.SystemInfo, 1)
+	if sw.header.ShuffleSeed != nil {
+		sw.write(",\n  \"shuffle_seed\": ", *sw.header.ShuffleSeed, 1)
+	}
+	if len(sw.header.Labels) > 0 {
+		sw.write(",\n  \"labels\": ", sw.header.Labels, 1)
+	}
+	if sw.err == nil {
+		sw.w.WriteString(",\n  \"results\": [")
+	}
+}
+
+// Add writes result and flushes it, so it is on disk before the next
+// benchmark starts
+func (sw *ResultStreamWriter) Add(result BenchmarkResult) error {
+	sw.open()
+	if sw.err != nil {
+		return sw.err
+	}
+	data, err := json.MarshalIndent(result, "    ", "  ")
+	if err != nil {
+		err = fmt.Errorf("result %s left out: %w", result.Name, err)
+		if sw.dropped == nil {
+			sw.dropped = err
+		}
+		return err
+	}
+	if len(sw.added) > 0 {
+		sw.w.WriteString(",")
+	}
+	sw.w.WriteString("\n    ")
+	sw.w.Write(data)
+	sw.err = sw.w.Flush()
+
+	// The aggregates need only these fields
+	sw.added = append(sw.added, BenchmarkResult{
+		Group:       result.Group,
+		Tags:        result.Tags,
+		Iterations:  result.Iterations,
+		TotalTimeNs: result.TotalTimeNs,
+		TotalOps:    result.TotalOps,
+		Stats:       BenchmarkStats{MeanNs: result.Stats.MeanNs},
+	})
+	return sw.err
+}
+
+// Close writes the end of the results and the suite aggregates, and
+// flushes. It returns the first error of the stream, a result left out
+// included, so the run can fail. It doesn't close the underlying writer.
+func (sw *ResultStreamWriter) Close() error {
+	sw.open()
+	tail := BenchmarkSuite{Results: sw.added}
+	tail.summarize()
+	if sw.err == nil {
+		sw.w.WriteString("\n  ]")
+	}
+	if len(tail.Groups) > 0 {
+		sw.write(",\n  \"groups\": ", tail.Groups, 1)
+	}
+	if len(tail.Tags) > 0 {
+		sw.write(",\n  \"tags\": ", tail.Tags, 1)
+	}
+	if tail.MeanNsGeoMean > 0 {
+		sw.write(",\n  \"geomean_mean_ns\": ", tail.MeanNsGeoMean, 1)
+		sw.write(",\n  \"geomean_throughput_ops_sec\": ", tail.ThroughputGeoMean, 1)
+	}
+	if sw.err == nil {
+		sw.w.WriteString("\n}\n")
+		sw.err = sw.w.Flush()
+	}
+	return cmp.Or(sw.dropped, sw.err)
+}
+
+// jsonResultsFile streams results into a JSON results file while the suite
+// runs, optionally with a checksum sidecar once it is complete
+type jsonResultsFile struct {
+	*ResultStreamWriter
+	path     string
+	file     *os.File
+	hash     hash.Hash
+	checksum bool
+}
+
+// createJSONResultsFile creates path and writes header to it
+func createJSONResultsFile(path string, header BenchmarkSuite, checksum bool) (*jsonResultsFile, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create results file: %w", err)
+	}
+	f := &jsonResultsFile{path: path, file: file, hash: sha256.New(), checksum: checksum}
+	f.ResultStreamWriter = NewResultStreamWriter(io.MultiWriter(file, f.hash), header)
+	return f, nil
+}
+
+// Close completes the file and writes its checksum sidecar if asked to
+func (f *jsonResultsFile) Close() error {
+	err := f.ResultStreamWriter.Close()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write results file %s: %w", f.path, err)
+	}
+	if f.checksum {
+		return writeChecksumFile(f.path, f.hash.Sum(nil))
+	}
+	return nil
+}
+
+// csvHeader lists the columns written by saveBenchmarkResultsCSV
+var csvHeader = []string{
+	"name", "iterations", "mean_ns", "median_ns", "min_ns", "max_ns",
+	"stddev_ns", "p95_ns", "p99_ns", "throughput_ops_sec", "size",
+}
+
+// saveBenchmarkResultsCSV writes one row per result to path for spreadsheet
+// analysis. Names containing commas or quotes are quoted per RFC 4180.
+func saveBenchmarkResultsCSV(results []BenchmarkResult, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create CSV results: %w", err)
+	}
+	defer file.Close()
+
+	formatNs := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+	w := csv.NewWriter(file)
+	w.Write(csvHeader)
+	for _, r := range results {
+		w.Write([]string{
+			r.Name,
+			strconv.Itoa(r.Iterations),
+			formatNs(r.Stats.MeanNs),
+			formatNs(r.Stats.MedianNs),
+			formatNs(r.Stats.MinNs),
+			formatNs(r.Stats.MaxNs),
+			formatNs(r.Stats.StddevNs),
+			formatNs(r.Stats.P95Ns),
+			formatNs(r.Stats.P99Ns),
+			formatNs(r.Throughput()),
+			strconv.Itoa(r.Size),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("write CSV results %s: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("write CSV results %s: %w", path, err)
+	}
+	return nil
+}
+
+// parseFormats parses the comma separated output formats of -format
+func parseFormats(value string) (writeJSON, writeCSV bool, err error) {
+	for _, format := range strings.Split(value, ",") {
+		switch strings.TrimSpace(format) {
+		case "json":
+			writeJSON = true
+		case "csv":
+			writeCSV = true
+		case "":
+		default:
+			return false, false, fmt.Errorf("unknown output format %q (want json, csv or json,csv)", format)
+		}
+	}
+	if !writeJSON && !writeCSV {
+		return false, false, fmt.Errorf("no output format given")
+	}
+	return writeJSON, writeCSV, nil
+}
+
+// writeChecksumFile writes a sha256sum-compatible sidecar (path + ".sha256")
+// holding the SHA-256 sum of the file, so archived results can also be
+// checked with `sha256sum -c`.
+func writeChecksumFile(path string, sum []byte) error {
+	line := fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum), filepath.Base(path))
+	if err := os.WriteFile(path+".sha256", []byte(line), 0644); err != nil {
+		return fmt.Errorf("write checksum for %s: %w", path, err)
+	}
+	return nil
+}
+
+// verifyChecksumFile checks data against the sidecar written by writeChecksumFile
+func verifyChecksumFile(path string, data []byte) error {
+	sidecar, err := os.ReadFile(path + ".sha256")
+	if err != nil {
+		return fmt.Errorf("read checksum for %s: %w", path, err)
+	}
+	fields := strings.Fields(string(sidecar))
+	if len(fields) == 0 {
+		return fmt.Errorf("checksum file %s.sha256 is empty", path)
+	}
+
+	sum := sha256.Sum256(data)
+	if actual := hex.EncodeToString(sum[:]); !strings.EqualFold(fields[0], actual) {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", path, fields[0], actual)
+	}
+	return nil
+}
+
+// loadBenchmarkSuite reads a results file written by a ResultStreamWriter.
+// When verify is set the file must match its .sha256 sidecar.
+func loadBenchmarkSuite(path string, verify bool) (BenchmarkSuite, error) {
+	var suite BenchmarkSuite
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return suite, fmt.Errorf("read results: %w", err)
+	}
+	if verify {
+		if err := verifyChecksumFile(path, data); err != nil {
+			return suite, err
+		}
+	}
+	if err := json.Unmarshal(data, &suite); err != nil {
+		return suite, fmt.Errorf("parse results %s: %w", path, err)
+	}
+	if err := attachSamplesSidecar(path, &suite); err != nil {
+		return suite, err
+	}
+	return suite, nil
+}
+
+// samplesSidecarSuffix names the file -samples-sidecar writes the raw
+// samples to, next to the results file
+const samplesSidecarSuffix = ".samples.json.gz"
+
+// writeSamplesSidecar writes raw samples keyed by result name as gzipped
+// JSON, keeping them out of the results file
+func writeSamplesSidecar(path string, samples map[string][]float64) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(f)
+	if err := json.NewEncoder(zw).Encode(samples); err != nil {
+		f.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if err := zw.Close(); err != nil {
+		f.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// attachSamplesSidecar fills in the samples of suite, loaded from path,
+// from the sidecar next to it. Having no sidecar is not an error.
+func attachSamplesSidecar(path string, suite *BenchmarkSuite) error {
+	f, err := os.Open(path + samplesSidecarSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", f.Name(), err)
+	}
+	var samples map[string][]float64
+	if err := json.NewDecoder(zr).Decode(&samples); err != nil {
+		return fmt.Errorf("parse %s: %w", f.Name(), err)
+	}
+	for i := range suite.Results {
+		if raw, ok := samples[suite.Results[i].Name]; ok && len(suite.Results[i].Samples) == 0 {
+			suite.Results[i].Samples = raw
+		}
+	}
+	return nil
+}
+
+// ResultWriter persists a finished benchmark suite somewhere other than the
+// default JSON file
+type ResultWriter interface {
+	WriteSuite(suite BenchmarkSuite) error
+}
+
+// sqliteMigrations are the schema versions of the SQLite export, applied in
+// order and tracked with PRAGMA user_version. Never edit an applied entry:
+// when result fields evolve, append a migration (e.g. ALTER TABLE ... ADD
+// COLUMN) so existing databases are upgraded in place. Until a field gets a
+// column it is still queryable from result_json with json_extract.
+var sqliteMigrations = []string{
+	`CREATE TABLE suites (
+	timestamp    INTEGER PRIMARY KEY,
+	go_version   TEXT NOT NULL,
+	os           TEXT NOT NULL,
+	arch         TEXT NOT NULL,
+	num_cpu      INTEGER NOT NULL,
+	shuffle_seed INTEGER
+);
+CREATE TABLE results (
+	name               TEXT NOT NULL,
+	timestamp          INTEGER NOT NULL REFERENCES suites(timestamp),
+	kind               TEXT,
+	iterations         INTEGER NOT NULL,
+	total_time_ns      REAL,
+	min_ns             REAL,
+	max_ns             REAL,
+	mean_ns            REAL,
+	median_ns          REAL,
+	stddev_ns          REAL,
+	p95_ns             REAL,
+	p99_ns             REAL,
+	stop_reason        TEXT,
+	alloc_bytes_per_op REAL,
+	allocs_per_op      REAL,
+	cpu_time_ns        REAL,
+	gc_count           INTEGER,
+	result_json        TEXT NOT NULL,
+	PRIMARY KEY (name, timestamp)
+);
+CREATE INDEX results_by_time ON results(timestamp);`,
+	`ALTER TABLE suites ADD COLUMN run_id TEXT;`,
+	`ALTER TABLE suites ADD COLUMN gomaxprocs INTEGER;
+ALTER TABLE suites ADD COLUMN gogc TEXT;
+ALTER TABLE suites ADD COLUMN cgo_enabled INTEGER;
+ALTER TABLE suites ADD COLUMN hostname TEXT;`,
+	// Suites are keyed by run ID instead of their timestamp, which two runs
+	// can share, and results by their position in the suite. Suites from
+	// before run IDs use their timestamp as one.
+	`CREATE TABLE suites_by_run (
+	run_id       TEXT PRIMARY KEY,
+	timestamp    INTEGER NOT NULL,
+	go_version   TEXT NOT NULL,
+	os           TEXT NOT NULL,
+	arch         TEXT NOT NULL,
+	num_cpu      INTEGER NOT NULL,
+	shuffle_seed INTEGER,
+	gomaxprocs   INTEGER,
+	gogc         TEXT,
+	cgo_enabled  INTEGER,
+	hostname     TEXT
+);
+INSERT INTO suites_by_run
+SELECT coalesce(nullif(run_id, ''), CAST(timestamp AS TEXT)), timestamp, go_version, os, arch, num_cpu,
+	shuffle_seed, gomaxprocs, gogc, cgo_enabled, hostname
+FROM suites;
+CREATE TABLE results_by_run (
+	run_id             TEXT NOT NULL REFERENCES suites(run_id),
+	seq                INTEGER NOT NULL,
+	name               TEXT NOT NULL,
+	kind               TEXT,
+	iterations         INTEGER NOT NULL,
+	total_time_ns      REAL,
+	min_ns             REAL,
+	max_ns             REAL,
+	mean_ns            REAL,
+	median_ns          REAL,
+	stddev_ns          REAL,
+	p95_ns             REAL,
+	p99_ns             REAL,
+	stop_reason        TEXT,
+	alloc_bytes_per_op REAL,
+	allocs_per_op      REAL,
+	cpu_time_ns        REAL,
+	gc_count           INTEGER,
+	result_json        TEXT NOT NULL,
+	PRIMARY KEY (run_id, seq)
+);
+INSERT INTO results_by_run
+SELECT coalesce(nullif(s.run_id, ''), CAST(s.timestamp AS TEXT)),
+	row_number() OVER (PARTITION BY r.timestamp ORDER BY r.rowid) - 1,
+	r.name, r.kind, r.iterations, r.total_time_ns, r.min_ns, r.max_ns, r.mean_ns, r.median_ns,
+	r.stddev_ns, r.p95_ns, r.p99_ns, r.stop_reason, r.alloc_bytes_per_op, r.allocs_per_op,
+	r.cpu_time_ns, r.gc_count, r.result_json
+FROM results r JOIN suites s USING (timestamp);
+DROP TABLE results;
+DROP TABLE suites;
+ALTER TABLE suites_by_run RENAME TO suites;
+ALTER TABLE results_by_run RENAME TO results;
+CREATE INDEX results_by_name ON results(name);`,
+}
+
+// sqliteResultWriter appends suites to a SQLite database, one row per
+// result keyed by the run ID and its position in the suite. It uses the
+// pure Go modernc.org/sqlite driver, so neither cgo nor the sqlite3 tool is
+// needed.
+type sqliteResultWriter struct {
+	path string
+}
+
+// WriteSuite migrates the database to the latest schema and inserts suite
+// in one transaction. A run is only written once: writing its suite again
+// fails on the run ID.
+func (w sqliteResultWriter) WriteSuite(suite BenchmarkSuite) error {
+	db, err := sql.Open("sqlite", w.path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("open %s: %w", w.path, err)
+	}
+	defer tx.Rollback() // a no-op once committed
+	var version int
+	if err := tx.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("read schema version of %s: %w", w.path, err)
+	}
+	if version > len(sqliteMigrations) {
+		return fmt.Errorf("%s has schema version %d, newer than this program's %d", w.path, version, len(sqliteMigrations))
+	}
+	for i, migration := range sqliteMigrations[version:] {
+		if _, err := tx.Exec(migration); err != nil {
+			return fmt.Errorf("migrate %s to schema version %d: %w", w.path, version+i+1, err)
+		}
+	}
+	// PRAGMA takes no parameters; the version is a number of ours
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", len(sqliteMigrations))); err != nil {
+		return err
+	}
+
+	info := suite.SystemInfo
+	if _, err := tx.Exec(`INSERT INTO suites (run_id, timestamp, go_version, os, arch, num_cpu, shuffle_seed,
+	gomaxprocs, gogc, cgo_enabled, hostname) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		info.RunID, info.Timestamp, info.GoVersion, info.OS, info.Arch, info.NumCPU, suite.ShuffleSeed,
+		info.GOMAXPROCS, info.GOGC, info.CgoEnabled, info.Hostname); err != nil {
+		return fmt.Errorf("insert run %s: %w", info.RunID, err)
+	}
+	insert, err := tx.Prepare(`INSERT INTO results (run_id, seq, name, kind, iterations, total_time_ns,
+	min_ns, max_ns, mean_ns, median_ns, stddev_ns, p95_ns, p99_ns, stop_reason, alloc_bytes_per_op,
+	allocs_per_op, cpu_time_ns, gc_count, result_json) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	for seq, r := range suite.Results {
+		resultJSON, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("encode %s: %w", r.Name, err)
+		}
+		if _, err := insert.Exec(info.RunID, seq, r.Name, r.Kind.String(), r.Iterations, sqlReal(r.TotalTimeNs),
+			sqlReal(r.Stats.MinNs), sqlReal(r.Stats.MaxNs), sqlReal(r.Stats.MeanNs), sqlReal(r.Stats.MedianNs),
+			sqlReal(r.Stats.StddevNs), sqlReal(r.Stats.P95Ns), sqlReal(r.Stats.P99Ns), r.StopReason,
+			sqlReal(r.AllocBytesPerOp), sqlReal(r.AllocsPerOp), sqlReal(r.CPUTimeNs), r.GCCount, string(resultJSON)); err != nil {
+			return fmt.Errorf("insert %s: %w", r.Name, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// sqlReal is v as a SQL parameter, NULL when it isn't finite
+func sqlReal(v float64) any {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return v
+}
+
+// prometheusGauges are the per-benchmark gauges WritePrometheus exposes
+var prometheusGauges = []struct {
+	name, help string
+	value      func(result BenchmarkResult) float64
+}{
+	{"benchmark_mean_ns", "Mean time per operation in nanoseconds.",
+		func(r BenchmarkResult) float64 { return r.Stats.MeanNs }},
+	{"benchmark_p99_ns", "99th percentile time per operation in nanoseconds.",
+		func(r BenchmarkResult) float64 { return r.Stats.P99Ns }},
+	{"benchmark_throughput_ops", "Operations per second.",
+		func(r BenchmarkResult) float64 { return r.Throughput() }},
+}
+
+// prometheusNameReplacer turns a benchmark name into a tidier label value;
+// quoting is still needed for the characters it leaves alone
+var prometheusNameReplacer = strings.NewReplacer(" ", "_", "(", "_", ")", "_")
+
+// prometheusLabelEscaper escapes a label value as the text format requires
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WritePrometheus writes suite's results as gauges in the Prometheus text
+// exposition format, one series per benchmark labelled with its name, e.g.
+// for node_exporter's textfile collector
+func WritePrometheus(w io.Writer, suite BenchmarkSuite) error {
+	// The suite labels follow name on every series, in key order
+	var extra strings.Builder
+	for _, key := range sortedLabelKeys(suite.Labels) {
+		fmt.Fprintf(&extra, ",%s=\"%s\"", key, prometheusLabelEscaper.Replace(suite.Labels[key]))
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, gauge := range prometheusGauges {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s gauge\n", gauge.name, gauge.help, gauge.name)
+		for _, result := range suite.Results {
+			label := prometheusLabelEscaper.Replace(prometheusNameReplacer.Replace(result.Name))
+			// FormatFloat spells non-finite values NaN, +Inf and -Inf as the format does
+			fmt.Fprintf(bw, "%s{name=\"%s\"%s} %s\n", gauge.name, label, extra.String(), strconv.FormatFloat(gauge.value(result), 'g', -1, 64))
+		}
+	}
+	return bw.Flush()
+}
+
+// prometheusResultWriter writes a suite to a Prometheus text file. The file
+// is replaced by a rename so a scraper never reads it half written.
+type prometheusResultWriter struct {
+	path string
+}
+
+func (w prometheusResultWriter) WriteSuite(suite BenchmarkSuite) error {
+	tmp, err := os.CreateTemp(filepath.Dir(w.path), filepath.Base(w.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+
+	if err := WritePrometheus(tmp, suite); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), w.path)
+}
+
+// markdownNameEscaper keeps a benchmark name from splitting a table cell
+var markdownNameEscaper = strings.NewReplacer("|", `\|`)
+
+// WriteMarkdown writes suite as a GitHub-flavored Markdown table, ready to
+// paste into a pull request, preceded by a blockquote with the system info
+// so the numbers don't lose their context
+func WriteMarkdown(w io.Writer, suite BenchmarkSuite) error {
+	bw := bufio.NewWriter(w)
+	info := suite.SystemInfo
+	fmt.Fprintf(bw, "> %s, %s/%s, %d CPUs, GOMAXPROCS=%d, %s", info.GoVersion, info.OS, info.Arch, info.NumCPU, info.GOMAXPROCS,
+		time.Unix(info.Timestamp, 0).UTC().Format("2006-01-02 15:04 MST"))
+	if info.RunID != "" {
+		fmt.Fprintf(bw, ", run %s", info.RunID)
+	}
+	if len(suite.Labels) > 0 {
+		fmt.Fprintf(bw, ", %s", formatLabels(suite.Labels))
+	}
+	fmt.Fprint(bw, "\n\n")
+	fmt.Fprintln(bw, "| Name | Iterations | Mean (ns) | Median (ns) | P99 (ns) | Throughput (ops/s) |")
+	fmt.Fprintln(bw, "| --- | ---: | ---: | ---: | ---: | ---: |")
+	for _, result := range suite.Results {
+		name := markdownNameEscaper.Replace(result.Name)
+		if result.Failed {
+			fmt.Fprintf(bw, "| %s | FAILED: %s | | | | |\n", name, markdownNameEscaper.Replace(result.Error))
+			continue
+		}
+		fmt.Fprintf(bw, "| %s | %d | %.0f | %.0f | %.0f | %s |\n", name, result.Iterations,
+			result.Stats.MeanNs, result.Stats.MedianNs, result.Stats.P99Ns, formatThroughput(result.Throughput()))
+	}
+	return bw.Flush()
+}
+
+// markdownResultWriter writes a suite as a Markdown table to a file, or to
+// stdout when path is "-"
+type markdownResultWriter struct {
+	path string
+}
+
+func (w markdownResultWriter) WriteSuite(suite BenchmarkSuite) error {
+	if w.path == "-" {
+		return WriteMarkdown(os.Stdout, suite)
+	}
+	f, err := os.Create(w.path)
+	if err != nil {
+		return err
+	}
+	if err := WriteMarkdown(f, suite); err != nil {
+		f.Close()
+		return fmt.Errorf("write %s: %w", w.path, err)
+	}
+	return f.Close()
+}
+
+// junitTestSuite and the types below are the subset of the JUnit XML format
+// CI dashboards read
+type junitTestSuite struct {
+	XMLName    xml.Name        `xml:"testsuite"`
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes suite as a JUnit XML test suite with one test case per
+// benchmark, so regressions show up as failed tests in CI. A benchmark fails
+// when its mean exceeds its entry in thresholds (ns) or when it panicked;
+// benchmarks without a threshold only fail by panicking.
+func WriteJUnit(w io.Writer, suite BenchmarkSuite, thresholds map[string]float64) error {
+	info := suite.SystemInfo
+	ts := junitTestSuite{
+		Name:      "go-benchmarks",
+		Tests:     len(suite.Results),
+		Timestamp: time.Unix(info.Timestamp, 0).UTC().Format("2006-01-02T15:04:05"),
+		Properties: []junitProperty{
+			{"go_version", info.GoVersion},
+			{"os", info.OS},
+			{"arch", info.Arch},
+			{"num_cpu", strconv.Itoa(info.NumCPU)},
+		},
+	}
+	if info.RunID != "" {
+		ts.Properties = append(ts.Properties, junitProperty{"run_id", info.RunID})
+	}
+	for _, key := range sortedLabelKeys(suite.Labels) {
+		ts.Properties = append(ts.Properties, junitProperty{key, suite.Labels[key]})
+	}
+
+	totalNs := 0.0
+	for _, result := range suite.Results {
+		totalNs += result.TotalTimeNs
+		testCase := junitTestCase{
+			Name:      result.Name,
+			ClassName: "benchmarks." + cmp.Or(result.Group, result.Kind.String()),
+			Time:      strconv.FormatFloat(result.TotalTimeNs/1e9, 'f', 3, 64),
+		}
+		threshold, ok := thresholds[result.Name]
+		switch {
+		case result.Failed:
+			testCase.Failure = &junitFailure{Message: "benchmark panicked: " + result.Error, Text: result.Error}
+		case ok && result.Stats.MeanNs > threshold:
+			testCase.Failure = &junitFailure{
+				Message: fmt.Sprintf("mean %.0f ns exceeds the %.0f ns threshold by %.1f%%",
+					result.Stats.MeanNs, threshold, (result.Stats.MeanNs/threshold-1)*100),
+				Text: fmt.Sprintf("mean %.1f ns (median %.1f ns, p99 %.1f ns, %d samples), threshold %.1f ns",
+					result.Stats.MeanNs, result.Stats.MedianNs, result.Stats.P99Ns, result.Iterations, threshold),
+			}
+		}
+		if testCase.Failure != nil {
+			ts.Failures++
+		}
+		ts.TestCases = append(ts.TestCases, testCase)
+	}
+	ts.Time = strconv.FormatFloat(totalNs/1e9, 'f', 3, 64)
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(xml.Header)
+	enc := xml.NewEncoder(bw)
+	enc.Indent("", "  ")
+	if err := enc.Encode(ts); err != nil {
+		return err
+	}
+	bw.WriteString("\n")
+	return bw.Flush()
+}
+
+// loadJUnitThresholds reads the -junit-thresholds file: a JSON object
+// mapping benchmark names to the highest acceptable mean in ns
+func loadJUnitThresholds(path string) (map[string]float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var thresholds map[string]float64
+	if err := json.Unmarshal(data, &thresholds); err != nil {
+		return nil, fmt.Errorf("parse thresholds %s: %w", path, err)
+	}
+	return thresholds, nil
+}
+
+// junitResultWriter writes a suite as JUnit XML to a file
+type junitResultWriter struct {
+	path       string
+	thresholds map[string]float64
+}
+
+func (w junitResultWriter) WriteSuite(suite BenchmarkSuite) error {
+	f, err := os.Create(w.path)
+	if err != nil {
+		return err
+	}
+	if err := WriteJUnit(f, suite, w.thresholds); err != nil {
+		f.Close()
+		return fmt.Errorf("write %s: %w", w.path, err)
+	}
+	return f.Close()
+}
+
+// sparkBlocks are the eight block heights used by sparkline
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline maps values onto the eight block heights, scaled between the
+// series' min and max. NaN marks a missing run and renders as a space; an
+// all-equal series renders at mid height so "flat" is distinguishable from "low".
+func sparkline(values []float64) string {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			lo = math.Min(lo, v)
+			hi = math.Max(hi, v)
+		}
+	}
+
+	var sb strings.Builder
+	for _, v := range values {
+		switch {
+		case math.IsNaN(v):
+			sb.WriteRune(' ')
+		case hi == lo:
+			sb.WriteRune(sparkBlocks[len(sparkBlocks)/2-1])
+		default:
+			level := int((v - lo) / (hi - lo) * float64(len(sparkBlocks)-1))
+			sb.WriteRune(sparkBlocks[level])
+		}
+	}
+	return sb.String()
+}
+
+// ANSI colors for terminal output
+const (
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorReset = "\033[0m"
+)
+
+// stdoutIsTerminal reports whether colored output makes sense on stdout:
+// it is a terminal and NO_COLOR (https://no-color.org) isn't set. Pipes
+// and files always get plain text.
+func stdoutIsTerminal() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// trendNoiseThreshold is the relative change below which a trend is left uncolored
+const trendNoiseThreshold = 0.02
+
+// printTrend loads historical result files and prints, per benchmark, a
+// sparkline of its mean and P99 over the last runs. Files are ordered by
+// their recorded timestamp, so shell glob order doesn't matter.
+func printTrend(paths []string, last int, verify bool) error {
+	suites := make([]BenchmarkSuite, 0, len(paths))
+	for _, path := range paths {
+		suite, err := loadBenchmarkSuite(path, verify)
+		if err != nil {
+			return err
+		}
+		suites = append(suites, suite)
+	}
+	if len(suites) == 0 {
+		return fmt.Errorf("trend needs at least one results file")
+	}
+	sort.SliceStable(suites, func(i, j int) bool {
+		return suites[i].SystemInfo.Timestamp < suites[j].SystemInfo.Timestamp
+	})
+	if last > 0 && len(suites) > last {
+		suites = suites[len(suites)-last:]
+	}
+
+	// Benchmarks keep the order they first appeared in
+	var names []string
+	seen := make(map[string]bool)
+	for _, suite := range suites {
+		for _, result := range suite.Results {
+			if !seen[result.Name] {
+				seen[result.Name] = true
+				names = append(names, result.Name)
+			}
+		}
+	}
+
+	width := fittedNameWidth(names)
+	fmt.Printf("\n=== Benchmark Trend (last %d runs) ===\n", len(suites))
+	fmt.Printf("%-*s %-*s  %-*s %12s %12s %9s\n", width, "Benchmark Name",
+		len(suites), "Mean", len(suites), "P99", "First Mean", "Last Mean", "Change")
+	for _, name := range names {
+		means := make([]float64, len(suites))
+		p99s := make([]float64, len(suites))
+		first, latest := math.NaN(), math.NaN()
+		for i, suite := range suites {
+			means[i], p99s[i] = math.NaN(), math.NaN()
+			for _, result := range suite.Results {
+				if result.Name == name {
+					means[i], p99s[i] = result.Stats.MeanNs, result.Stats.P99Ns
+					if math.IsNaN(first) {
+						first = result.Stats.MeanNs
+					}
+					latest = result.Stats.MeanNs
+					break
+				}
+			}
+		}
+
+		change := (latest - first) / first
+		fmt.Printf("%-*s %s  %s %12s %12s %s\n",
+			width, name, tintChange(sparkline(means), change), sparkline(p99s), formatDuration(first), formatDuration(latest), formatChange(change))
+	}
+	return nil
+}
+
+// uTestAlpha is the significance level of UTest
+const uTestAlpha = 0.05
+
+// UTest runs a two-sided Mann-Whitney U test of a and b coming from the same
+// distribution. Unlike comparing means it is robust to the skew and
+// outliers of latency samples. The p-value uses the normal approximation
+// with a tie correction, which is accurate from about 8 samples per side;
+// it is 1 when either side is empty. significant is p < 0.05.
+func UTest(a, b []float64) (pValue float64, significant bool) {
+	n1, n2 := len(a), len(b)
+	if n1 == 0 || n2 == 0 {
+		return 1, false
+	}
+
+	// Rank the pooled samples, ties getting their average rank
+	type sample struct {
+		value float64
+		fromA bool
+	}
+	pooled := make([]sample, 0, n1+n2)
+	for _, x := range a {
+		pooled = append(pooled, sample{x, true})
+	}
+	for _, x := range b {
+		pooled = append(pooled, sample{x, false})
+	}
+	sort.Slice(pooled, func(i, j int) bool { return pooled[i].value < pooled[j].value })
+
+	rankSumA, tieTerm := 0.0, 0.0
+	for i := 0; i < len(pooled); {
+		j := i
+		for j < len(pooled) && pooled[j].value == pooled[i].value {
+			j++
+		}
+		rank := float64(i+j+1) / 2 // average of the 1-based ranks i+1..j
+		for k := i; k < j; k++ {
+			if pooled[k].fromA {
+				rankSumA += rank
+			}
+		}
+		ties := float64(j - i)
+		tieTerm += ties*ties*ties - ties
+		i = j
+	}
+
+	u := rankSumA - float64(n1)*float64(n1+1)/2
+	n := float64(n1 + n2)
+	mean := float64(n1) * float64(n2) / 2
+	variance := float64(n1) * float64(n2) / 12 * ((n + 1) - tieTerm/(n*(n-1)))
+	if variance <= 0 {
+		// Every sample is equal
+		return 1, false
+	}
+	// Continuity correction towards the mean
+	z := (math.Abs(u-mean) - 0.5) / math.Sqrt(variance)
+	pValue = math.Min(1, math.Erfc(math.Max(z, 0)/math.Sqrt2))
+	return pValue, pValue < uTestAlpha
+}
+
+// BenchmarkComparison pairs one benchmark's result in a baseline suite with
+// the same benchmark in another suite. Means are NaN where it is missing.
+type BenchmarkComparison struct {
+	Name       string  `json:"name"`
+	BaseMeanNs float64 `json:"base_mean_ns"`
+	MeanNs     float64 `json:"mean_ns"`
+	// Delta is the relative change of the mean; negative means faster
+	Delta float64 `json:"delta"`
+
+	// Set when both results kept their samples: the UTest p-value and
+	// whether the change is significant rather than noise
+	SamplesCompared bool    `json:"samples_compared,omitempty"`
+	PValue          float64 `json:"p_value,omitempty"`
+	Significant     bool    `json:"significant,omitempty"`
+}
+
+// CompareSuites matches benchmarks by name. The result follows the baseline's
+// order, followed by benchmarks that only exist in current.
+func CompareSuites(base, current BenchmarkSuite) []BenchmarkComparison {
+	byName := make(map[string]BenchmarkResult, len(current.Results))
+	for _, result := range current.Results {
+		byName[result.Name] = result
+	}
+
+	var comparisons []BenchmarkComparison
+	seen := make(map[string]bool, len(base.Results))
+	for _, result := range base.Results {
+		seen[result.Name] = true
+		other, ok := byName[result.Name]
+		mean := other.Stats.MeanNs
+		if !ok {
+			mean = math.NaN()
+		}
+		comparison := BenchmarkComparison{
+			Name:       result.Name,
+			BaseMeanNs: result.Stats.MeanNs,
+			MeanNs:     mean,
+			Delta:      (mean - result.Stats.MeanNs) / result.Stats.MeanNs,
+		}
+		if len(result.Samples) > 0 && len(other.Samples) > 0 {
+			comparison.SamplesCompared = true
+			comparison.PValue, comparison.Significant = UTest(result.Samples, other.Samples)
+		}
+		comparisons = append(comparisons, comparison)
+	}
+	for _, result := range current.Results {
+		if !seen[result.Name] {
+			comparisons = append(comparisons, BenchmarkComparison{
+				Name:       result.Name,
+				BaseMeanNs: math.NaN(),
+				MeanNs:     result.Stats.MeanNs,
+				Delta:      math.NaN(),
+			})
+		}
+	}
+	return comparisons
+}
+
+// compareColumnWidth is the width of one result file's column in -compare-multi
+const compareColumnWidth = 24
+
+// printMultiComparison prints one row per benchmark and one column per
+// result file, every column compared against the first file as baseline.
+// Benchmarks missing from a file are left blank.
+func printMultiComparison(paths []string, verify bool) error {
+	if len(paths) < 2 {
+		return fmt.Errorf("compare-multi needs a baseline and at least one other results file")
+	}
+	suites := make([]BenchmarkSuite, len(paths))
+	for i, path := range paths {
+		suite, err := loadBenchmarkSuite(path, verify)
+		if err != nil {
+			return err
+		}
+		suites[i] = suite
+	}
+
+	for i, suite := range suites[1:] {
+		if !suite.SystemInfo.sameCPU(suites[0].SystemInfo) {
+			fmt.Fprintf(os.Stderr, "warning: %s ran on %s (%.0f MHz), the baseline on %s (%.0f MHz); timings from different CPUs aren't comparable\n",
+				paths[i+1], suite.SystemInfo.CPUModel, suite.SystemInfo.CPUMHz,
+				suites[0].SystemInfo.CPUModel, suites[0].SystemInfo.CPUMHz)
+		}
+	}
+
+	// Row order: the baseline's benchmarks, then newcomers as they appear
+	var names []string
+	seen := make(map[string]bool)
+	columns := make([]map[string]BenchmarkComparison, len(suites))
+	for i, suite := range suites {
+		columns[i] = make(map[string]BenchmarkComparison)
+		for _, comparison := range CompareSuites(suites[0], suite) {
+			if !math.IsNaN(comparison.MeanNs) {
+				columns[i][comparison.Name] = comparison
+			}
+			if !seen[comparison.Name] {
+				seen[comparison.Name] = true
+				names = append(names, comparison.Name)
+			}
+		}
+	}
+
+	fmt.Printf("\n=== Benchmark Comparison (baseline: %s) ===\n", paths[0])
+	for i, suite := range suites {
+		if len(suite.Labels) > 0 {
+			fmt.Printf("Labels of %s: %s\n", filepath.Base(paths[i]), formatLabels(suite.Labels))
+		}
+	}
+	nameWidth := fittedNameWidth(names)
+
+	fmt.Printf("%-*s", nameWidth, "Benchmark Name")
+	for _, path := range paths {
+		header := filepath.Base(path)
+		if len(header) > compareColumnWidth {
+			header = "..." + header[len(header)-compareColumnWidth+3:]
+		}
+		fmt.Printf(" %*s", compareColumnWidth, header)
+	}
+	fmt.Println()
+
+	for _, name := range names {
+		fmt.Printf("%-*s", nameWidth, name)
+		for i := range suites {
+			comparison, ok := columns[i][name]
+			var cell string
+			switch {
+			case !ok:
+				cell = ""
+			case i == 0 || math.IsNaN(comparison.Delta):
+				cell = formatDuration(comparison.MeanNs)
+			case comparison.SamplesCompared && !comparison.Significant:
+				// Like benchstat: the samples don't show a change
+				cell = formatDuration(comparison.MeanNs) + " (~)"
+			default:
+				cell = fmt.Sprintf("%s (%+.1f%%)", formatDuration(comparison.MeanNs), comparison.Delta*100)
+				cell = tintChange(fmt.Sprintf("%*s", compareColumnWidth, cell), comparison.Delta)
+			}
+			fmt.Printf(" %*s", compareColumnWidth, cell)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// labelFlag collects repeated -label key=value flags. Keys must be valid
+// Prometheus label names, so every export can carry them unchanged.
+type labelFlag map[string]string
+
+func (l labelFlag) String() string {
+	return formatLabels(l)
+}
+
+func (l labelFlag) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("want key=value, got %q", value)
+	}
+	if !prometheusLabelName.MatchString(key) || key == "name" || strings.HasPrefix(key, "__") {
+		return fmt.Errorf("invalid label key %q: want letters, digits and underscores, not \"name\" or a \"__\" prefix", key)
+	}
+	l[key] = val
+	return nil
+}
+
+// prometheusLabelName matches the label names the Prometheus text format allows
+var prometheusLabelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+func sortedLabelKeys(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatLabels renders labels as "k1=v1, k2=v2" in key order
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for _, key := range sortedLabelKeys(labels) {
+		pairs = append(pairs, key+"="+labels[key])
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// parseShuffle interprets -shuffle the way `go test -shuffle` does:
+// "off", "on" (seeded from the clock) or an explicit seed
+func parseShuffle(value string) (*int64, error) {
+	switch value {
+	case "off", "":
+		return nil, nil
+	case "on":
+		seed := time.Now().UnixNano()
+		return &seed, nil
+	}
+	seed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("-shuffle must be off, on or an integer seed, got %q", value)
+	}
+	return &seed, nil
+}
+
+// registerBenchmarks registers the suite in the order it runs and is reported
+func registerBenchmarks() {
+	// Tags group benchmarks across kinds for -tags and the per-tag
+	// geometric means: compute, concurrency, io and memory.
+
+	// Core Go benchmarks. Scheduler-heavy benchmarks stay KindUnspecified:
+	// locking the calling goroutine to its OS thread would distort them.
+	registerBenchmark("Goroutine Creation & Execution", KindUnspecified, benchmarkGoroutineCreationAndExecution, "concurrency")
+	registerBenchmark("Channel Operations", KindCPUBound, benchmarkChannelOps, "concurrency")
+	registerBenchmark("Simple Computation", KindCPUBound, benchmarkSimpleComputation, "compute")
+
+	// 复杂任务基准测试 - 测试调度器能力
+	registerBenchmark("Complex Computation Task", KindCPUBound, benchmarkComplexComputation, "compute")
+
+	registerBenchmark("Data Processing Task", KindCPUBound, benchmarkDataProcessingTask, "compute")
+	registerBenchmark("Request Handler Task", KindCPUBound, benchmarkRequestHandlerTask, "compute")
+	registerBenchmark("Batch Processing Task", KindCPUBound, benchmarkBatchProcessingTask, "compute")
+	registerBenchmark("Concurrent Task Processing", KindUnspecified, benchmarkConcurrentTaskProcessing, "concurrency")
+	registerSweep("Concurrent Task Scaling", KindUnspecified, benchmarkConcurrentTaskScaling, "concurrency")
+
+	// Concurrency benchmarks
+	registerBenchmark("Concurrent Goroutines (10)", KindIOBound, benchmarkConcurrentGoroutines, "concurrency")
+	registerSweep("Goroutine Pool vs Spawn", KindUnspecified, benchmarkGoroutinePoolVsSpawn, "concurrency")
+	registerSweep("Spawn vs Pool A/B", KindUnspecified, benchmarkSpawnVsPoolAB, "concurrency")
+	registerSweep("Channel Close/Drain", KindUnspecified, benchmarkChannelCloseDrain, "concurrency")
+	registerSweep("sync.Pool Concurrency", KindUnspecified, benchmarkSyncPoolConcurrency, "concurrency", "memory")
+	registerSweep("sync.Cond Broadcast", KindUnspecified, benchmarkCondBroadcast, "concurrency")
+	registerSweep("Concurrent Counters", KindUnspecified, benchmarkConcurrentCounters, "concurrency")
+	registerSweep("Parallel Map Reads", KindUnspecified, benchmarkParallelMapReads, "concurrency")
+
+	// Memory benchmarks
+	registerBenchmark("Memory Allocation (1KB)", KindMemoryBound, benchmarkMemoryAllocation, "memory")
+	registerSweep("Allocation Rate", KindMemoryBound, benchmarkAllocationRateSweep, "memory")
+
+	// Network and IO simulation benchmarks
+	registerBenchmark("Echo Server Throughput", KindIOBound, benchmarkEchoServer, "io")
+	registerBenchmark("Concurrent Echo Clients", KindIOBound, benchmarkConcurrentEchoClients, "io", "concurrency")
+	registerBenchmark("HTTP Request Processing", KindIOBound, benchmarkHTTPProcessing, "io")
+	registerSweep("Rate Limiter", KindCPUBound, benchmarkRateLimiter, "concurrency")
+
+	// Standard library benchmarks
+	registerSweep("slices Package", KindCPUBound, benchmarkSlicesPackage, "compute")
+	registerSweep("Sorting APIs", KindCPUBound, benchmarkSortAPIs, "compute")
+	registerSweep("Slice Copy", KindMemoryBound, benchmarkSliceCopy, "memory")
+	registerSweep("Map Clear", KindMemoryBound, benchmarkMapClear, "memory")
+	registerSweep("Float Loops", KindCPUBound, benchmarkFloatLoops, "compute")
+	registerSweep("math/bits", KindCPUBound, benchmarkMathBits, "compute")
+
+	// Language-level benchmarks
+	registerSweep("Defer Placement", KindCPUBound, benchmarkDeferPlacement, "compute")
+	registerSweep("Interface Dispatch", KindCPUBound, benchmarkInterfaceDispatch, "compute")
+	registerSweep("Generics vs interface{}", KindCPUBound, benchmarkGenericsVsInterface, "compute")
+
+	// Data processing benchmarks
+	registerSweep("Line Reading", KindIOBound, benchmarkLineReading, "io")
+	registerSweep("Regexp", KindCPUBound, benchmarkRegexp, "compute")
+	registerSweep("strconv vs fmt", KindCPUBound, benchmarkStrconv, "compute")
+	registerSweep("gob vs JSON", KindCPUBound, benchmarkGobVsJSON, "compute")
+	registerSweep("time Parse/Format", KindCPUBound, benchmarkTimeLayouts, "compute")
+	registerSweep("unsafe Conversions", KindCPUBound, benchmarkUnsafeConversions, "compute")
+	registerSweep("Escape Analysis", KindMemoryBound, benchmarkEscapeAnalysis, "memory")
+	registerSweep("log/slog Handlers", KindCPUBound, benchmarkSlogHandlers, "io")
+	registerSweep("context.WithTimeout", KindCPUBound, benchmarkContextTimeout, "concurrency")
+
+	// Data transfer benchmarks
+	registerSweep("Data Transfer", KindMemoryBound, benchmarkDataTransfer, "memory")
+}
+
+// printKindSummary groups the results by benchmark kind
+func printKindSummary(results []BenchmarkResult) {
+	counts := make(map[BenchmarkKind]int)
+	for _, result := range results {
+		counts[result.Kind]++
+	}
+
+	fmt.Println("\n=== Results by Kind ===")
+	for _, kind := range []BenchmarkKind{KindCPUBound, KindIOBound, KindMemoryBound, KindUnspecified} {
+		if counts[kind] == 0 {
+			continue
+		}
+		fmt.Printf("  %-12s %3d benchmarks", kind, counts[kind])
+		switch kind {
+		case KindCPUBound:
+			fmt.Print("  (OS thread locked; compare compute and scheduling cost)")
+		case KindIOBound:
+			fmt.Print("  (GC disabled, 2x time budget; latency dominated, expect higher variance)")
+		case KindMemoryBound:
+			fmt.Print("  (allocations tracked; see Alloc/op in detailed statistics)")
+		case KindUnspecified:
+			fmt.Print("  (no kind defaults; scheduler-heavy benchmarks)")
+		}
+		fmt.Println()
+	}
+}
+
+// printAllocSummary ranks the benchmarks whose allocations were measured,
+// most allocations per op first, so allocation-heavy code stands out
+func printAllocSummary(results []BenchmarkResult) {
+	var measured []BenchmarkResult
+	for _, result := range results {
+		if result.AllocsMeasured {
+			measured = append(measured, result)
+		}
+	}
+	if len(measured) == 0 {
+		return
+	}
+	sort.SliceStable(measured, func(i, j int) bool {
+		if measured[i].AllocsPerOp != measured[j].AllocsPerOp {
+			return measured[i].AllocsPerOp > measured[j].AllocsPerOp
+		}
+		return measured[i].AllocBytesPerOp > measured[j].AllocBytesPerOp
+	})
+
+	width := resultNameWidth(measured)
+	fmt.Println("\n=== Allocations by Benchmark ===")
+	fmt.Printf("%-*s %12s %12s %12s\n", width, "Benchmark Name", "Allocs/op", "Bytes/op", "Mean Time")
+	free := 0
+	for _, result := range measured {
+		if result.AllocsPerOp == 0 {
+			free++
+			continue
+		}
+		fmt.Printf("%-*s %12.2f %10.0f B %12s\n",
+			width, result.Name, result.AllocsPerOp, result.AllocBytesPerOp, formatDuration(result.Stats.MeanNs))
+	}
+	if free > 0 {
+		fmt.Printf("%d of %d measured benchmarks allocate nothing per op\n", free, len(measured))
+	}
+}
+
+func main() {
+	checksum := flag.Bool("checksum", false, "write a .sha256 sidecar next to the JSON results")
+	verify := flag.String("verify", "", "verify a results file against its .sha256 sidecar and exit")
+	histogram := flag.Bool("histogram", false, "print a latency histogram for every benchmark")
+	warmupTime := flag.Duration("warmup-time", 0, "warm every benchmark up for this long instead of a fixed 10 calls, e.g. 200ms")
+	adaptiveWarmup := flag.Bool("adaptive-warmup", false, "keep warming up until the mean of the last warmup calls changes by less than 2%")
+	debugWarmup := flag.Bool("debug-warmup", false, "print every warmup iteration's duration to stderr")
+	trend := flag.Bool("trend", false, "print per-benchmark sparklines for the result files given as arguments and exit")
+	trendLast := flag.Int("trend-last", 10, "number of most recent runs shown by -trend")
+	trimOutliers := flag.Bool("trim-outliers", false, "also report the mean without IQR outliers and how many samples that drops")
+	benchmem := flag.Bool("benchmem", false, "report allocations per op for every benchmark, like go test -benchmem")
+	allocSizes := flag.Bool("alloc-sizes", false, "track allocations and report their size class distribution")
+	keepSamples := flag.Bool("keep-samples", false, "store every raw sample in the JSON results so -compare-multi can tell changes from noise with a Mann-Whitney U test")
+	samplesSidecar := flag.Bool("samples-sidecar", false, "keep the raw samples (implies -keep-samples) in a gzipped "+samplesSidecarSuffix+" file next to the JSON results instead of inside them")
+	streamingStats := flag.Bool("streaming-stats", false, "estimate median, P95 and P99 in constant memory instead of keeping every sample")
+	rawNsFlag := flag.Bool("raw-ns", false, "print durations on the console as plain ns instead of scaling them to µs, ms or s")
+	gcClean := flag.Bool("gc-clean", false, "report GC-free and GC-affected statistics separately, telling samples apart by whether a GC cycle completed during them")
+	gcPauses := flag.Bool("gc-pauses", false, "report the GC pause time and longest pause during every benchmark's measured loop")
+	subtractOverhead := flag.Bool("subtract-overhead", false, "subtract the calibrated cost of timing an empty closure from every sample")
+	batchSize := flag.Int("batch", 1, "time this many calls per sample and record their average, like go test's benchmark loop; 1 times every call")
+	autoBatch := flag.Bool("auto-batch", false, "time calibrated batches of calls instead of single calls")
+	heapBallastMB := flag.Int("heap-ballast-mb", 0, "run every benchmark with this much live heap ballast (MB) to add GC pressure")
+	shuffle := flag.String("shuffle", "off", "randomize benchmark order: off, on, or a seed to reproduce an order")
+	labels := labelFlag{}
+	flag.Var(labels, "label", "tag the results with a key=value label; repeatable")
+	baseline := flag.String("baseline", "", "results file to compare against: summary rows get the change of their mean vs it")
+	compareMulti := flag.Bool("compare-multi", false, "compare the result files given as arguments against the first one and exit")
+	targetRSE := flag.Float64("target-rse", 0, "instead of a time budget, sample each benchmark until the relative standard error of its mean drops to this (e.g. 0.01), up to max iterations")
+	minSamples := flag.Int("min-samples", DefaultRunnerConfig().MinSamples, "keep measuring past the time budget until this many samples are taken (capped at max iterations); 0 disables")
+	budget := flag.Duration("budget", 0, "split this total time budget evenly across the selected benchmarks instead of giving each its own (e.g. 30s)")
+	redistribute := flag.Bool("redistribute", false, "with -budget, give time left unused by fast benchmarks to the remaining ones")
+	filter := flag.String("filter", "", "only run benchmarks whose name, or the name of the group they belong to (see -list), matches this regexp")
+	tagList := flag.String("tags", "", "only run benchmarks with any of these comma separated tags (e.g. io,compute,concurrency; see -list)")
+	category := flag.String("category", "", "only run benchmarks of these comma separated categories (cpu, io, memory, unspecified)")
+	listJSON := flag.Bool("json", false, "with -list, print the selected result names as a JSON array instead")
+	list := flag.Bool("list", false, "list the selected benchmarks with their estimated run time, then exit")
+	estimate := flag.Bool("estimate", false, "print the estimated run time of the selected benchmarks without running them, then exit")
+	listCategories := flag.Bool("list-categories", false, "list benchmark categories with their size and estimated run time, then exit")
+	streamLatency := flag.String("stream-latency", "", "run only the benchmark with this result name, streaming every sample as NDJSON to stdout")
+	streamDuration := flag.Duration("stream-duration", 0, "how long -stream-latency runs; 0 runs until interrupted")
+	failOnUnreliable := flag.Bool("fail-on-unreliable", false, "exit non-zero if any benchmark fails the reliability checks (CV, clock resolution, optimized away)")
+	flag.Float64Var(&noisyCV, "noisy-cv", noisyCV, "coefficient of variation above which the summary marks a benchmark as noisy")
+	maxCV := flag.Float64("max-cv", 1.0, "highest coefficient of variation -fail-on-unreliable accepts")
+	jsonOut := flag.String("json-out", "go_benchmark_results.json", "path of the JSON results file; empty writes none")
+	format := flag.String("format", "json", "comma separated result file formats: json, csv")
+	tagArtifacts := flag.Bool("tag-artifacts", false, "embed the run ID in output file names so runs never overwrite each other")
+	prometheusPath := flag.String("prometheus", "", "also write the results to this file in the Prometheus text format")
+	markdownPath := flag.String("markdown", "", "also write the results as a Markdown table to this file, or to stdout with -")
+	junitPath := flag.String("junit", "", "also write the results as JUnit XML to this file, one test case per benchmark")
+	junitThresholds := flag.String("junit-thresholds", "", "JSON object of benchmark name to max mean ns; -junit marks benchmarks above it as failed")
+	sqlitePath := flag.String("sqlite", "", "also append the results to this SQLite database")
+	cpuProfile := flag.String("cpuprofile", "", "write a pprof CPU profile of the measured loop of the one benchmark -filter selects; its timings include the profiling overhead")
+	requireChecksum := flag.Bool("require-checksum", false, "verify the .sha256 sidecar of every loaded result file")
+	flag.Parse()
+	rawNs = *rawNsFlag
+
+	if *trend {
+		if err := printTrend(flag.Args(), *trendLast, *requireChecksum); err != nil {
+			fmt.Fprintf(os.Stderr, "Trend failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *verify != "" {
+		suite, err := loadBenchmarkSuite(*verify, true)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Verification failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s: checksum OK (%d results)\n", *verify, len(suite.Results))
+		return
+	}
+
+	if *compareMulti {
+		if err := printMultiComparison(flag.Args(), *requireChecksum); err != nil {
+			fmt.Fprintf(os.Stderr, "Comparison failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	shuffleSeed, err := parseShuffle(*shuffle)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	categories, err := parseCategories(*category)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	writeJSON, writeCSV, err := parseFormats(*format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *targetRSE < 0 {
+		fmt.Fprintf(os.Stderr, "-target-rse must not be negative, got %g\n", *targetRSE)
+		os.Exit(2)
+	}
+	if *batchSize < 1 {
+		fmt.Fprintf(os.Stderr, "-batch must be at least 1, got %d\n", *batchSize)
+		os.Exit(2)
+	}
+	if *minSamples < 0 {
+		fmt.Fprintf(os.Stderr, "-min-samples must not be negative, got %d\n", *minSamples)
+		os.Exit(2)
+	}
+	if *warmupTime < 0 {
+		fmt.Fprintf(os.Stderr, "-warmup-time must not be negative, got %s\n", *warmupTime)
+		os.Exit(2)
+	}
+	var filterRE *regexp.Regexp
+	if *filter != "" {
+		if filterRE, err = regexp.Compile(*filter); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -filter: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	if *baseline != "" {
+		base, err := loadBenchmarkSuite(*baseline, *requireChecksum)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Loading baseline failed: %v\n", err)
+			os.Exit(1)
+		}
+		summaryBaseline = make(map[string]float64, len(base.Results))
+		for _, result := range base.Results {
+			summaryBaseline[result.Name] = result.Stats.MeanNs
+		}
+	}
+
+	var thresholds map[string]float64
+	if *junitThresholds != "" {
+		if thresholds, err = loadJUnitThresholds(*junitThresholds); err != nil {
+			fmt.Fprintf(os.Stderr, "Loading JUnit thresholds failed: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	registerBenchmarks()
+	tags, err := parseTags(*tagList)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -tags: %v\n", err)
+		os.Exit(2)
+	}
+	opts := suiteOptions{
+		debugWarmup:           *debugWarmup,
+		adaptiveWarmup:        *adaptiveWarmup,
+		warmupTime:            *warmupTime,
+		trimOutliers:          *trimOutliers,
+		benchmem:              *benchmem,
+		gcPauses:              *gcPauses,
+		streamingStats:        *streamingStats,
+		keepSamples:           *keepSamples || *samplesSidecar,
+		gcClean:               *gcClean,
+		allocSizes:            *allocSizes,
+		autoBatch:             *autoBatch,
+		batchSize:             *batchSize,
+		targetRSE:             *targetRSE,
+		heapBallastBytes:      *heapBallastMB << 20,
+		minSamples:            *minSamples,
+		subtractTimerOverhead: *subtractOverhead,
+		shuffleSeed:           shuffleSeed,
+		categories:            categories,
+		tags:                  tags,
+		filter:                filterRE,
+		budget:                *budget,
+		redistribute:          *redistribute,
+	}
+
+	if *listCategories {
+		printCategories(opts)
+		return
+	}
+	if *cpuProfile != "" {
+		if runs := opts.selectedResults(); runs != 1 {
+			fmt.Fprintf(os.Stderr, "-cpuprofile needs a -filter selecting exactly one result, this one selects %d\n", runs)
+			os.Exit(2)
+		}
+		opts.cpuProfile = *cpuProfile
+	}
+
+	// The first Ctrl-C cancels the run and still gets a (partial) report;
+	// restoring the default handler lets a second one kill the process
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	// kill -USR1 prints where the run is without disturbing it
+	statusSignals := make(chan os.Signal, 1)
+	notifyProgressSignal(statusSignals)
+	go func() {
+		for range statusSignals {
+			printProgress(os.Stderr)
+		}
+	}()
+	opts.ctx = ctx
+
+	if *streamLatency != "" {
+		// stdout carries only NDJSON; Ctrl-C ends the stream cleanly
+		opts.stream = &latencyStream{ctx: ctx, name: *streamLatency, duration: *streamDuration, sink: newNDJSONSink(os.Stdout)}
+		runRegisteredBenchmarks(opts)
+		if !opts.stream.matched {
+			fmt.Fprintf(os.Stderr, "no benchmark result named %q; use a name from the summary table\n", *streamLatency)
+			os.Exit(2)
+		}
+		if opts.stream.err != nil {
+			fmt.Fprintf(os.Stderr, "Streaming failed: %v\n", opts.stream.err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *list && *listJSON {
+		if err := writeBenchmarkListJSON(os.Stdout, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Listing failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *list || *estimate {
+		if *list {
+			printBenchmarkList(opts)
+		}
+		if *estimate {
+			printSuiteEstimate(opts)
+		}
+		return
+	}
+
+	runID := newRunID()
+	printSystemInfo()
+	if resolution := clockResolution(); resolution > coarseClockThreshold {
+		fmt.Fprintf(os.Stderr, "warning: clock resolution is %s; samples shorter than a few steps, e.g. Channel Operations or Simple Computation, will mostly show clock granularity, consider -batch or -auto-batch\n", resolution)
+	}
+	fmt.Printf("Run ID: %s\n", runID)
+	if shuffleSeed != nil {
+		fmt.Printf("Shuffle seed: %d (rerun with -shuffle %d to reproduce the order)\n", *shuffleSeed, *shuffleSeed)
+	}
+	systemInfo := newSystemInfo()
+	systemInfo.RunID = runID
+
+	// The JSON file is written as results come in, so a long run can be
+	// inspected before it finishes
+	artifactRunID := ""
+	if *tagArtifacts {
+		artifactRunID = runID
+	}
+	var jsonFile *jsonResultsFile
+	sidecarSamples := make(map[string][]float64)
+	jsonPath := artifactPath(*jsonOut, artifactRunID)
+	if writeJSON && *jsonOut != "" {
+		header := BenchmarkSuite{SystemInfo: systemInfo, ShuffleSeed: shuffleSeed, Labels: labels}
+		if jsonFile, err = createJSONResultsFile(jsonPath, header, *checksum); err != nil {
+			fmt.Printf("Error saving JSON results: %v\n", err)
+		} else {
+			opts.onResult = func(result BenchmarkResult) {
+				if *samplesSidecar && len(result.Samples) > 0 {
+					sidecarSamples[result.Name] = result.Samples
+					result.Samples = nil
+				}
+				if err := jsonFile.Add(result); err != nil {
+					fmt.Fprintf(os.Stderr, "warning: streaming to %s: %v\n", jsonPath, err)
+				}
+			}
+		}
+	}
+	printBenchmarkHeader()
+
+	results := runRegisteredBenchmarks(opts)
+	if ctx.Err() != nil {
+		fmt.Printf("\nInterrupted: reporting the %d results collected so far (the last one may be partial)\n", len(results))
+	}
+
+	// Print summary
+	printSummaryTable(results)
+
+	printBenchmarkFooter()
+	printKindSummary(results)
+	printAllocSummary(results)
+	printStopReasonSummary(results)
+	printScalingReport(results)
+
+	suite := newBenchmarkSuite(systemInfo, results)
+	suite.ShuffleSeed = shuffleSeed
+	suite.Labels = labels
+	printGeoMean(suite)
+	printTagSummary(suite)
+	if worst, cv, ok := suite.WorstCV(); ok {
+		fmt.Printf("Noisiest benchmark: %s (CV %.2f)\n", worst.Name, cv)
+	}
+
+	// Finish the JSON results
+	var artifacts []string
+	jsonFailed := false
+	if jsonFile != nil {
+		if err := jsonFile.Close(); err != nil {
+			fmt.Printf("Error saving JSON results: %v\n", err)
+			jsonFailed = true
+		} else {
+			fmt.Printf("\nGo benchmark results saved to %s\n", jsonPath)
+			if *checksum {
+				fmt.Printf("Checksum saved to %s.sha256\n", jsonPath)
+			}
+			artifacts = append(artifacts, "json="+jsonPath)
+		}
+		if len(sidecarSamples) > 0 {
+			sidecarPath := jsonPath + samplesSidecarSuffix
+			if err := writeSamplesSidecar(sidecarPath, sidecarSamples); err != nil {
+				fmt.Printf("Error saving raw samples: %v\n", err)
+			} else {
+				fmt.Printf("Raw samples saved to %s\n", sidecarPath)
+				artifacts = append(artifacts, "samples="+sidecarPath)
+			}
+		}
+	}
+	if writeCSV {
+		csvPath := artifactPath("go_benchmark_results.csv", artifactRunID)
+		if err := saveBenchmarkResultsCSV(results, csvPath); err != nil {
+			fmt.Printf("Error saving CSV results: %v\n", err)
+		} else {
+			fmt.Printf("CSV results saved to %s\n", csvPath)
+			artifacts = append(artifacts, "csv="+csvPath)
+		}
+	}
+	if *prometheusPath != "" {
+		var writer ResultWriter = prometheusResultWriter{path: *prometheusPath}
+		if err := writer.WriteSuite(suite); err != nil {
+			fmt.Printf("Error writing Prometheus results: %v\n", err)
+		} else {
+			fmt.Printf("Prometheus metrics written to %s\n", *prometheusPath)
+		}
+	}
+	if *markdownPath != "" {
+		var writer ResultWriter = markdownResultWriter{path: *markdownPath}
+		if err := writer.WriteSuite(suite); err != nil {
+			fmt.Printf("Error writing Markdown results: %v\n", err)
+		} else if *markdownPath != "-" {
+			fmt.Printf("Markdown table written to %s\n", *markdownPath)
+		}
+	}
+	if *junitPath != "" {
+		var writer ResultWriter = junitResultWriter{path: *junitPath, thresholds: thresholds}
+		if err := writer.WriteSuite(suite); err != nil {
+			fmt.Printf("Error writing JUnit results: %v\n", err)
+		} else {
+			fmt.Printf("JUnit results written to %s\n", *junitPath)
+		}
+	}
+	if *sqlitePath != "" {
+		var writer ResultWriter = sqliteResultWriter{path: *sqlitePath}
+		if err := writer.WriteSuite(suite); err != nil {
+			fmt.Printf("Error writing SQLite results: %v\n", err)
+		} else {
+			fmt.Printf("Results appended to %s\n", *sqlitePath)
+		}
+	}
+	if *cpuProfile != "" && len(failedBenchmarks(results)) == 0 {
+		fmt.Printf("CPU profile written to %s (timings above include the profiling overhead)\n", *cpuProfile)
+	}
+
+	// Print detailed statistics for key benchmarks
+	fmt.Println("\n=== Detailed Statistics ===")
+	for _, result := range results {
+		if result.Name == "Goroutine Creation" ||
+			result.Name == "Echo Server Simulation" ||
+			result.Name == "HTTP Request Processing" ||
+			result.Name == "Request Handler Task" ||
+			result.Name == "Concurrent Task Processing" ||
+			result.Name == "Concurrent Echo Clients" ||
+			result.Group == dataTransferGroup ||
+			result.AllocsMeasured || len(result.Metrics) > 0 {
+			result.PrintDetailed()
+		}
+	}
+	if *histogram {
+		fmt.Println("\n=== Latency Histograms ===")
+		for _, result := range results {
+			if len(result.Stats.Buckets) > 0 {
+				result.PrintHistogram()
+			}
+		}
+	}
+
+	// Machine-readable summary, last so `tail -1` finds it; the run ID ties
+	// this log to the artifacts it produced
+	summary := append([]string{"run_id=" + runID, fmt.Sprintf("results=%d", len(results))}, artifacts...)
+	fmt.Printf("\nBENCHMARK_RUN %s\n", strings.Join(summary, " "))
+
+	// Checked after saving so the results of a failed run can be inspected
+	if jsonFailed {
+		os.Exit(1)
+	}
+	if *failOnUnreliable && !checkReliability(results, *maxCV) {
+		os.Exit(1)
+	}
+	if failed := failedBenchmarks(results); len(failed) > 0 {
+		fmt.Printf("%d benchmarks failed: %s\n", len(failed), strings.Join(failed, ", "))
+		os.Exit(1)
+	}
+}
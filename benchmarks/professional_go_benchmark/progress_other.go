@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// notifyProgressSignal does nothing where there is no SIGUSR1
+func notifyProgressSignal(c chan<- os.Signal) {}
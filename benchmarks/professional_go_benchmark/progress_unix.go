@@ -0,0 +1,15 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyProgressSignal relays SIGUSR1 to c, for printing the progress of
+// a run with kill -USR1
+func notifyProgressSignal(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGUSR1)
+}
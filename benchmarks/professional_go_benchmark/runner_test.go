@@ -0,0 +1,223 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// newTestRunner returns a runner with a short time budget and few samples,
+// adjusted by configure if it isn't nil
+func newTestRunner(t *testing.T, configure func(config *RunnerConfig)) *BenchmarkRunner {
+	t.Helper()
+	config := DefaultRunnerConfig()
+	config.WarmupIterations = 1
+	config.MinIterations = 10
+	config.MinSamples = 0
+	config.MaxIterations = 50
+	config.MinBenchmarkTime = 10 * time.Millisecond
+	if configure != nil {
+		configure(&config)
+	}
+	runner, err := NewBenchmarkRunnerWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return runner
+}
+
+func TestRunTimedExcludesStoppedTime(t *testing.T) {
+	const setup = time.Millisecond
+	runner := newTestRunner(t, nil)
+	result := runner.RunTimed("timed", func(timer *Timer) {
+		timer.Stop()
+		time.Sleep(setup)
+		timer.Start()
+	})
+	if result.Iterations == 0 {
+		t.Fatal("no samples were taken")
+	}
+	// Only the Start call is timed, far below the sleep however loaded the machine
+	if result.Stats.MeanNs >= float64(setup.Nanoseconds())/2 {
+		t.Errorf("mean %s includes the %s of setup the timer was stopped for", formatDuration(result.Stats.MeanNs), setup)
+	}
+}
+
+func TestMinSamplesExtendsShortRuns(t *testing.T) {
+	const minSamples = 1000
+	// A 1 ns budget runs out after the first pass, whatever the closure costs
+	configure := func(config *RunnerConfig) {
+		config.MinBenchmarkTime = time.Nanosecond
+		config.MinSamples = minSamples
+		config.MaxIterations = 10 * minSamples
+	}
+
+	result := newTestRunner(t, configure).Run("empty", func() {})
+	if result.Iterations < minSamples {
+		t.Errorf("Iterations = %d, want at least %d", result.Iterations, minSamples)
+	}
+	if result.StopReason != StopMinSamples {
+		t.Errorf("StopReason = %q, want %q", result.StopReason, StopMinSamples)
+	}
+
+	// A share of the suite's -budget isn't extended
+	runner := newTestRunner(t, configure)
+	runner.budgeted = true
+	result = runner.Run("empty", func() {})
+	if result.Iterations >= minSamples {
+		t.Errorf("budgeted: Iterations = %d, want fewer than %d", result.Iterations, minSamples)
+	}
+	if result.StopReason != StopSuiteBudget {
+		t.Errorf("budgeted: StopReason = %q, want %q", result.StopReason, StopSuiteBudget)
+	}
+}
+
+func TestSubtractTimerOverheadLowersEmptyMean(t *testing.T) {
+	empty := func() {}
+	raw := newTestRunner(t, nil).Run("empty", empty)
+
+	runner := newTestRunner(t, func(config *RunnerConfig) { config.SubtractTimerOverhead = true })
+	if runner.TimerOverhead() <= 0 {
+		t.Skip("the timer overhead calibrated to 0 on this clock")
+	}
+	subtracted := runner.Run("empty", empty)
+	if subtracted.Stats.MeanNs >= raw.Stats.MeanNs {
+		t.Errorf("mean with %s overhead subtracted is %s, not below the raw %s",
+			runner.TimerOverhead(), formatDuration(subtracted.Stats.MeanNs), formatDuration(raw.Stats.MeanNs))
+	}
+	if subtracted.Stats.MinNs < 0 {
+		t.Errorf("MinNs = %g, samples should be clamped at 0", subtracted.Stats.MinNs)
+	}
+}
+
+// worstOvershoot returns the largest ratio by which the passes of runner
+// overshoot a sample target past the first pass, over targets up to limit
+func worstOvershoot(runner *BenchmarkRunner, limit int) float64 {
+	worst := 1.0
+	total, pass := runner.minIterations, runner.nextPassIterations(runner.minIterations)
+	for total < limit {
+		// The targets in (total, total+pass] are first met after this pass,
+		// the one just past total overshot most
+		worst = max(worst, float64(total+pass)/float64(total+1))
+		total += pass
+		pass = runner.nextPassIterations(pass)
+	}
+	return worst
+}
+
+func TestFinerGrowthFactorOvershootsLess(t *testing.T) {
+	const limit = 1_000_000
+	overshoot := make(map[float64]float64)
+	for _, factor := range []float64{2, 1.5} {
+		runner := newTestRunner(t, func(config *RunnerConfig) { config.GrowthFactor = factor })
+		for pass := 1; pass < limit; pass = runner.nextPassIterations(pass) {
+			if next := runner.nextPassIterations(pass); next > int(math.Ceil(float64(pass)*factor)) {
+				t.Fatalf("factor %g: pass of %d grew to %d", factor, pass, next)
+			}
+		}
+		overshoot[factor] = worstOvershoot(runner, limit)
+	}
+	// A time budget runs out at the sample count the closure's rate fits in it,
+	// so the samples past that target are time spent past the budget
+	if overshoot[1.5] >= overshoot[2] {
+		t.Errorf("factor 1.5 overshoots a sample target by up to %.2fx, not less than the %.2fx of factor 2", overshoot[1.5], overshoot[2])
+	}
+}
+
+func TestSeparateGCSamplesDoesntAllocate(t *testing.T) {
+	runner := newTestRunner(t, func(config *RunnerConfig) {
+		config.TrackAllocs = true
+		config.SeparateGCSamples = true
+	})
+	result := runner.Run("empty", func() {})
+	if !result.AllocsMeasured {
+		t.Fatal("allocations were not measured")
+	}
+	if result.AllocsPerOp != 0 || result.AllocBytesPerOp != 0 {
+		t.Errorf("empty closure: %.2f allocs/op and %.0f B/op, want 0", result.AllocsPerOp, result.AllocBytesPerOp)
+	}
+}
+
+func TestRunABAlternatesOrder(t *testing.T) {
+	runner := newTestRunner(t, func(config *RunnerConfig) { config.WarmupIterations = 0 })
+	var calls []byte
+	resultA, resultB := runner.RunAB("ab", func() { calls = append(calls, 'A') }, func() { calls = append(calls, 'B') })
+	if resultA.Iterations < 2 || resultB.Iterations != resultA.Iterations {
+		t.Fatalf("A took %d samples and B %d, want as many and at least 2", resultA.Iterations, resultB.Iterations)
+	}
+	for round := 0; 2*round+1 < len(calls); round++ {
+		want := "AB"
+		if round%2 == 1 {
+			want = "BA"
+		}
+		if got := string(calls[2*round : 2*round+2]); got != want {
+			t.Fatalf("round %d called %s, want %s", round, got, want)
+		}
+	}
+}
+
+func TestRunABAppliesRunnerSettings(t *testing.T) {
+	const batch = 4
+	runner := newTestRunner(t, func(config *RunnerConfig) {
+		config.WarmupIterations = 0
+		config.BatchSize = batch
+		config.TrackAllocs = true
+	})
+	callsB := 0
+	resultA, resultB := runner.RunAB("ab",
+		func() { allocationSink = make([]byte, 64) },
+		func() { callsB++ })
+
+	if resultA.BatchSize != batch || resultB.BatchSize != batch {
+		t.Errorf("BatchSize = %d and %d, want %d", resultA.BatchSize, resultB.BatchSize, batch)
+	}
+	// Every sample times a batch, then the allocations are counted over as many untimed calls
+	if want := 2 * resultB.Iterations * batch; callsB != want {
+		t.Errorf("B was called %d times for %d samples, want %d", callsB, resultB.Iterations, want)
+	}
+	if !resultA.AllocsMeasured || resultA.AllocsPerOp < 1 {
+		t.Errorf("A: %.2f allocs/op measured %t, want at least 1", resultA.AllocsPerOp, resultA.AllocsMeasured)
+	}
+	if resultB.AllocsPerOp != 0 {
+		t.Errorf("B: %.2f allocs/op, want 0", resultB.AllocsPerOp)
+	}
+	if resultA.TotalTimeNs <= 0 || resultA.TotalTimeNs != resultB.TotalTimeNs {
+		t.Errorf("TotalTimeNs = %g and %g, want the same wall time", resultA.TotalTimeNs, resultB.TotalTimeNs)
+	}
+}
+
+func TestParallelWorkersRunEachWorkerOncePerOp(t *testing.T) {
+	const workers, ops = 8, 1000
+	var calls [workers]int // each only written by its worker, read after wg.Wait
+	p := newParallelWorkers(workers, func(worker int) { calls[worker]++ })
+	for i := 0; i < ops; i++ {
+		p.run()
+	}
+	p.close()
+	for worker, n := range calls {
+		if n != ops {
+			t.Errorf("worker %d ran %d times over %d ops", worker, n, ops)
+		}
+	}
+}
+
+func TestBudgetedPass(t *testing.T) {
+	tests := []struct {
+		name            string
+		pass, samples   int
+		elapsed, budget int64
+		want            int
+	}{
+		{"first pass", 100, 0, 0, 1000, 100},
+		{"clock not ticked yet", 100, 50, 0, 1000, 100},
+		{"half the budget left", 100, 50, 500, 1000, 51},
+		{"more left than the pass", 100, 10, 10, 1000, 100},
+		{"budget just spent", 100, 50, 1000, 1000, 1},
+	}
+	for _, tt := range tests {
+		if got := budgetedPass(tt.pass, tt.samples, tt.elapsed, tt.budget); got != tt.want {
+			t.Errorf("%s: budgetedPass(%d, %d, %d, %d) = %d, want %d",
+				tt.name, tt.pass, tt.samples, tt.elapsed, tt.budget, got, tt.want)
+		}
+	}
+}
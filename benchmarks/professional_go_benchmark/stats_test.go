@@ -0,0 +1,209 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// ascending returns the samples 1, 2, ..., n
+func ascending(n int) []float64 {
+	samples := make([]float64, n)
+	for i := range samples {
+		samples[i] = float64(i + 1)
+	}
+	return samples
+}
+
+// ascendingFrom returns the n samples from, from+1, ...
+func ascendingFrom(from, n int) []float64 {
+	samples := ascending(n)
+	for i := range samples {
+		samples[i] += float64(from - 1)
+	}
+	return samples
+}
+
+func approxEqual(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+func TestCalculatePercentiles(t *testing.T) {
+	// On 1..n the type 7 quantile p is 1 + p*(n-1)
+	for _, n := range []int{1, 2, 20, 101, 10000} {
+		var stats BenchmarkStats
+		stats.Calculate(ascending(n))
+
+		want := map[string][2]float64{
+			"min":    {stats.MinNs, 1},
+			"max":    {stats.MaxNs, float64(n)},
+			"median": {stats.MedianNs, 1 + 0.5*float64(n-1)},
+			"p95":    {stats.P95Ns, 1 + 0.95*float64(n-1)},
+			"p99":    {stats.P99Ns, 1 + 0.99*float64(n-1)},
+		}
+		for name, got := range want {
+			if !approxEqual(got[0], got[1], 1e-9) {
+				t.Errorf("n=%d: %s = %g, want %g", n, name, got[0], got[1])
+			}
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		sorted []float64
+		p      float64
+		want   float64
+	}{
+		{nil, 0.5, 0},
+		{[]float64{7}, 0.99, 7},
+		{[]float64{10, 20}, 0.5, 15},
+		{[]float64{10, 20}, 0.95, 19.5},
+		{[]float64{10, 20}, 1, 20},
+		{[]float64{1, 2, 3, 4}, 0.25, 1.75},
+		{[]float64{1, 2, 3, 4}, 0, 1},
+	}
+	for _, tt := range tests {
+		if got := percentile(tt.sorted, tt.p); !approxEqual(got, tt.want, 1e-9) {
+			t.Errorf("percentile(%v, %g) = %g, want %g", tt.sorted, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestTrimOutliersBimodal(t *testing.T) {
+	// 90 fast samples of 100..109 ns and 10 slow ones of 1000 ns, e.g. GC hits
+	var samples []float64
+	for i := 0; i < 90; i++ {
+		samples = append(samples, float64(100+i/9))
+	}
+	for i := 0; i < 10; i++ {
+		samples = append(samples, 1000)
+	}
+
+	var stats BenchmarkStats
+	stats.TrimOutliers(samples) // already sorted
+	if stats.OutliersRemoved != 10 {
+		t.Errorf("OutliersRemoved = %d, want 10", stats.OutliersRemoved)
+	}
+	if !approxEqual(stats.TrimmedMeanNs, 104.5, 1e-9) {
+		t.Errorf("TrimmedMeanNs = %g, want 104.5", stats.TrimmedMeanNs)
+	}
+}
+
+// normalSamples returns n seeded samples of mean 1000 ns and stddev 100 ns
+func normalSamples(n int, seed int64) []float64 {
+	rng := rand.New(rand.NewSource(seed))
+	samples := make([]float64, n)
+	for i := range samples {
+		samples[i] = 1000 + 100*rng.NormFloat64()
+	}
+	return samples
+}
+
+func TestConfidenceIntervalNarrowsWithSamples(t *testing.T) {
+	lastWidth := math.Inf(1)
+	for _, n := range []int{5, 29, 30, 100, 1000, 10000} {
+		var stats BenchmarkStats
+		stats.Calculate(normalSamples(n, 1))
+		if !(stats.MeanCILowNs < stats.MeanNs && stats.MeanNs < stats.MeanCIHighNs) {
+			t.Errorf("n=%d: interval [%g, %g] doesn't contain the mean %g", n, stats.MeanCILowNs, stats.MeanCIHighNs, stats.MeanNs)
+		}
+		width := stats.MeanCIHighNs - stats.MeanCILowNs
+		if width >= lastWidth {
+			t.Errorf("n=%d: interval width %g, not narrower than %g with fewer samples", n, width, lastWidth)
+		}
+		lastWidth = width
+	}
+}
+
+func TestCalculateLeavesSamplesUnchanged(t *testing.T) {
+	samples := []float64{5, 1, 4, 2, 3, 9, 0.5}
+	original := slices.Clone(samples)
+
+	var stats BenchmarkStats
+	stats.Calculate(samples)
+	if !slices.Equal(samples, original) {
+		t.Errorf("Calculate reordered its input to %v, want %v", samples, original)
+	}
+	if stats.MedianNs != 3 {
+		t.Errorf("MedianNs = %g, want 3", stats.MedianNs)
+	}
+}
+
+func TestStreamingStatsMatchExact(t *testing.T) {
+	distributions := []struct {
+		name string
+		next func(rng *rand.Rand) float64
+	}{
+		{"normal", func(rng *rand.Rand) float64 { return 1000 + 100*rng.NormFloat64() }},
+		{"uniform", func(rng *rand.Rand) float64 { return 500 + 1000*rng.Float64() }},
+		// Skewed like real timings, with a long tail for p99 to track
+		{"exponential", func(rng *rand.Rand) float64 { return 100 + 1000*rng.ExpFloat64() }},
+	}
+	for _, dist := range distributions {
+		rng := rand.New(rand.NewSource(1))
+		samples := make([]float64, 100000)
+		stream := newStreamingStats()
+		var moments runningStats
+		for i := range samples {
+			samples[i] = dist.next(rng)
+			stream.add(samples[i])
+			moments.add(samples[i])
+		}
+
+		var exact BenchmarkStats
+		exact.Calculate(samples)
+		got := stream.stats(moments, defaultConfidenceLevel)
+		quantiles := map[string][2]float64{
+			"median": {got.MedianNs, exact.MedianNs},
+			"p95":    {got.P95Ns, exact.P95Ns},
+			"p99":    {got.P99Ns, exact.P99Ns},
+		}
+		for name, q := range quantiles {
+			if !approxEqual(q[0], q[1], 0.02*q[1]) {
+				t.Errorf("%s: streaming %s = %g, exact %g", dist.name, name, q[0], q[1])
+			}
+		}
+		if !approxEqual(got.MeanNs, exact.MeanNs, 1e-6*exact.MeanNs) {
+			t.Errorf("%s: streaming mean = %g, exact %g", dist.name, got.MeanNs, exact.MeanNs)
+		}
+		if got.MinNs != exact.MinNs || got.MaxNs != exact.MaxNs {
+			t.Errorf("%s: streaming range [%g, %g], exact [%g, %g]", dist.name, got.MinNs, got.MaxNs, exact.MinNs, exact.MaxNs)
+		}
+	}
+}
+
+func TestUTest(t *testing.T) {
+	same := normalSamples(100, 1)
+	shifted := slices.Clone(same)
+	for i := range shifted {
+		shifted[i] += 100 // one stddev slower
+	}
+	tests := []struct {
+		name            string
+		a, b            []float64
+		minP, maxP      float64
+		wantSignificant bool
+	}{
+		{"identical", same, same, 1, 1, false},
+		{"same distribution", same, normalSamples(100, 2), 0.05, 1, false},
+		{"every sample equal", []float64{5, 5, 5}, []float64{5, 5}, 1, 1, false},
+		{"empty", nil, same, 1, 1, false},
+		{"shifted by a stddev", same, shifted, 0, 1e-6, true},
+		// U = 0: z = 49.5/sqrt(175), the two-sided p of which is 1.8267e-4
+		{"disjoint", ascending(10), ascendingFrom(11, 10), 1.826e-4, 1.827e-4, true},
+	}
+	for _, tt := range tests {
+		p, significant := UTest(tt.a, tt.b)
+		if p < tt.minP || p > tt.maxP {
+			t.Errorf("%s: p = %g, want in [%g, %g]", tt.name, p, tt.minP, tt.maxP)
+		}
+		if significant != tt.wantSignificant {
+			t.Errorf("%s: significant = %t, want %t", tt.name, significant, tt.wantSignificant)
+		}
+		if reversed, _ := UTest(tt.b, tt.a); reversed != p {
+			t.Errorf("%s: p = %g with the sides swapped, want %g", tt.name, reversed, p)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+// testingBResults caches RunTestingB's result per benchmark name, since
+// go test calls a benchmark function several times while it sizes b.N
+var testingBResults sync.Map // b.Name() -> BenchmarkResult
+
+// RunTestingB bridges go test benchmarks in this package to the runner:
+//
+//	func BenchmarkParse(b *testing.B) {
+//		result := NewBenchmarkRunner().RunTestingB(b, parse)
+//		...
+//	}
+//
+// go test times fn b.N times for ns/op as usual, and the runner's statistics
+// for it are added as the p99_ns, stddev_ns and ops/s metrics. The runner
+// measures fn once per benchmark name, with b's timer stopped, and every
+// call returns that result, e.g. for a JSON file next to the go test output.
+// It lives in a test file so the benchmark binary doesn't link testing.
+func (br *BenchmarkRunner) RunTestingB(b *testing.B, fn func()) BenchmarkResult {
+	b.StopTimer()
+	cached, ok := testingBResults.Load(b.Name())
+	if !ok {
+		cached, _ = testingBResults.LoadOrStore(b.Name(), br.Run(b.Name(), fn))
+	}
+	result := cached.(BenchmarkResult)
+	b.StartTimer()
+
+	for i := 0; i < b.N; i++ {
+		fn()
+	}
+
+	b.ReportMetric(result.Stats.P99Ns, "p99_ns")
+	b.ReportMetric(result.Stats.StddevNs, "stddev_ns")
+	b.ReportMetric(result.Throughput(), "ops/s")
+	return result
+}
+
+func BenchmarkPoolTask(b *testing.B) {
+	result := NewBenchmarkRunner().RunTestingB(b, poolTask)
+	if result.Iterations == 0 {
+		b.Fatal("the runner measured no iterations")
+	}
+}